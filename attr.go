@@ -3,29 +3,54 @@ package zradius
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/rand"
 	"encoding/binary"
+	"fmt"
 	"hash"
-	"net"
 
 	"github.com/andrewz1/zradius/zdict"
 )
 
 // Attr - Radius attr storage
 type Attr struct {
-	typ   byte            // Attr type
-	len   byte            // Attr len
-	vid   uint32          // VendorID for VSA
-	vtyp  byte            // VendorType for VSA
-	vlen  byte            // VendorLen for VSA
-	tag   byte            // Attr TAG for tagged atrtributess
-	data  []byte          // raw attr data
-	edata interface{}     // evaluated attr data
-	atyp  *zdict.AttrData // Attr data from dictionary, nil if not found in dictionary
-	dcr   bool            // "decrypted" flag for encrypted Attr
-}
-
-// decrypt User-Password attr
-func (attr *Attr) decryptUsr(pkt *Packet) {
+	typ   byte              // Attr type
+	len   byte              // Attr len
+	ext   byte              // Extended-Type for extended Attr (RFC 6929)
+	vid   uint32            // VendorID for VSA
+	vtyp  uint32            // VendorType for VSA
+	vlen  byte              // VendorLen for VSA
+	vend  *zdict.VendorData // Vendor from dictionary for VSA, nil for unknown vendor
+	tag   byte              // Attr TAG for tagged atrtributess
+	tagd  bool              // TAG byte is present, tag 0 is valid value
+	data  []byte            // raw attr data
+	edata interface{}       // evaluated attr data
+	atyp  *zdict.AttrData   // Attr data from dictionary, nil if not found in dictionary
+	dcr   bool              // "decrypted" flag for encrypted Attr
+}
+
+// User-Password limits (RFC 2865)
+const (
+	usrMaxPass = 128 // max password len
+)
+
+// More flag of Long-Extended-Type attr (RFC 6929)
+const extFlagMore = 0x80
+
+// More flag of WiMAX continuation byte
+const vsaFlagMore = 0x80
+
+// Max tag value for tagged attrs (RFC 2868)
+const tagMax = 0x1F
+
+// Tunnel-Password limits (RFC 2868)
+const (
+	tunSaltLen = 2                            // salt len
+	tunMaxLen  = 255 - 2 - 1 - tunSaltLen     // max encrypted data len
+	tunMaxPass = tunMaxLen - tunMaxLen%16 - 1 // max password len
+)
+
+// decrypt User-Password attr, attr is left encrypted on error
+func (attr *Attr) decryptUsr(pkt *Packet) error {
 	var (
 		l, i, j, s int
 		sh         hash.Hash
@@ -34,13 +59,13 @@ func (attr *Attr) decryptUsr(pkt *Packet) {
 	)
 
 	if attr.dcr {
-		return
+		return nil
 	}
-	defer func() {
-		attr.dcr = true
-	}()
-	if l = len(attr.data); (l % 16) != 0 {
-		return
+	if pkt.auth == zeroAuth {
+		return fmt.Errorf("User-Password can't be decrypted without request authenticator")
+	}
+	if l = len(attr.data); l == 0 || (l%16) != 0 {
+		return fmt.Errorf("User-Password encrypted len error, len: %d", l)
 	}
 	sh = md5.New()
 	sh.Write(pkt.secret)
@@ -67,48 +92,526 @@ func (attr *Attr) decryptUsr(pkt *Packet) {
 	}
 	attr.data = dst[:s]
 	attr.len = byte(s + 2)
+	attr.dcr = true
+	return nil
 }
 
-// GetData - return raw attr data
+// encrypt User-Password attr data, ra - request authenticator
+func (attr *Attr) encryptUsr(pkt *Packet, ra []byte) ([]byte, error) {
+	var (
+		l, i, j int
+		sh      hash.Hash
+		xor     []byte
+		dst     []byte
+	)
+
+	if len(attr.data) > usrMaxPass {
+		return nil, fmt.Errorf("User-Password too long, len: %d, max: %d", len(attr.data), usrMaxPass)
+	}
+	// zero padding to 16 bytes boundary, at least one block
+	if l = len(attr.data); l == 0 || (l%16) != 0 {
+		l += 16 - l%16
+	}
+	dst = make([]byte, l)
+	copy(dst, attr.data)
+	sh = md5.New()
+	sh.Write(pkt.secret)
+	sh.Write(ra)
+	xor = sh.Sum(nil)
+	for {
+		for i = 0; i < 16; i++ {
+			dst[j] ^= xor[i]
+			j++
+		}
+		if j >= l {
+			break
+		}
+		sh.Reset()
+		sh.Write(pkt.secret)
+		sh.Write(dst[j-16 : j])
+		xor = sh.Sum(nil)
+	}
+	return dst, nil
+}
+
+// decrypt Tunnel-Password attr, attr is left encrypted on error
+func (attr *Attr) decryptTun(pkt *Packet) error {
+	var (
+		l, i, j, s int
+		sh         hash.Hash
+		xor        []byte
+		dst        []byte
+		src        []byte
+	)
+
+	if attr.dcr {
+		return nil
+	}
+	if isReply(pkt.code) && pkt.req == nil { // reply is encrypted with request authenticator
+		return ErrNoReq
+	}
+	// tag + salt + at least one 16 byte block
+	if l = len(attr.data) - 1 - tunSaltLen; l < 16 || (l%16) != 0 {
+		return fmt.Errorf("Tunnel-Password encrypted len error, len: %d", len(attr.data))
+	}
+	if attr.data[1]&0x80 == 0 {
+		return fmt.Errorf("Tunnel-Password salt high bit is not set")
+	}
+	src = attr.data[1+tunSaltLen:]
+	sh = md5.New()
+	sh.Write(pkt.secret)
+	sh.Write(pkt.reqAuth())
+	sh.Write(attr.data[1 : 1+tunSaltLen])
+	xor = sh.Sum(nil)
+	dst = make([]byte, l)
+	for {
+		for i = 0; i < 16; i++ {
+			dst[j] = src[j] ^ xor[i]
+			j++
+		}
+		if j >= l {
+			break
+		}
+		s = j - 16 // start byte
+		sh.Reset()
+		sh.Write(pkt.secret)
+		sh.Write(src[s:j])
+		xor = sh.Sum(nil)
+	}
+	// first byte is password len
+	if s = int(dst[0]); s > l-1 {
+		return fmt.Errorf("Tunnel-Password len error, len: %d, max: %d", s, l-1)
+	}
+	attr.tag = attr.data[0]
+	attr.tagd = true
+	attr.data = dst[1 : s+1]
+	attr.len = byte(s + 2)
+	attr.dcr = true
+	return nil
+}
+
+// encrypt Tunnel-Password attr data, ra - request authenticator
+func (attr *Attr) encryptTun(pkt *Packet, ra []byte) ([]byte, error) {
+	var (
+		l, i, j, s int
+		sh         hash.Hash
+		xor        []byte
+		dst        []byte
+		src        []byte
+	)
+
+	if len(attr.data) > tunMaxPass {
+		return nil, fmt.Errorf("Tunnel-Password too long, len: %d, max: %d", len(attr.data), tunMaxPass)
+	}
+	// len byte + password + zero padding
+	if l = len(attr.data) + 1; (l % 16) != 0 {
+		l += 16 - l%16
+	}
+	src = make([]byte, l)
+	src[0] = byte(len(attr.data))
+	copy(src[1:], attr.data)
+	dst = make([]byte, 1+tunSaltLen+l)
+	dst[0] = attr.tag
+	if _, err := rand.Read(dst[1 : 1+tunSaltLen]); err != nil {
+		return nil, err
+	}
+	dst[1] |= 0x80 // salt high bit must be set
+	sh = md5.New()
+	sh.Write(pkt.secret)
+	sh.Write(ra)
+	sh.Write(dst[1 : 1+tunSaltLen])
+	xor = sh.Sum(nil)
+	s = 1 + tunSaltLen // encrypted data start
+	for {
+		for i = 0; i < 16; i++ {
+			dst[s+j] = src[j] ^ xor[i]
+			j++
+		}
+		if j >= l {
+			break
+		}
+		sh.Reset()
+		sh.Write(pkt.secret)
+		sh.Write(dst[s+j-16 : s+j])
+		xor = sh.Sum(nil)
+	}
+	return dst, nil
+}
+
+// decrypt attr data if attr is encrypted
+func (attr *Attr) decrypt(pkt *Packet) error {
+	if attr.atyp == nil {
+		return nil
+	}
+	switch attr.atyp.Enc {
+	case zdict.EncUsr:
+		return attr.decryptUsr(pkt)
+	case zdict.EncTun:
+		return attr.decryptTun(pkt)
+	}
+	return nil
+}
+
+// encrypted - attr data is still encrypted
+func (attr *Attr) encrypted() bool {
+	return attr.atyp != nil && attr.atyp.Enc != zdict.EncNone && !attr.dcr
+}
+
+// split tag from data for tagged attr (RFC 2868)
+func (attr *Attr) splitTag() {
+	if attr.atyp == nil || !attr.atyp.Tag || attr.atyp.Enc == zdict.EncTun { // Tunnel-Password tag is split on decrypt
+		return
+	}
+	if attr.atyp.Dtyp == zdict.TypeInt { // tag is in first byte of integer, value is 3 bytes
+		if len(attr.data) == 4 {
+			attr.tag = attr.data[0]
+			attr.tagd = true
+			attr.data = []byte{0, attr.data[1], attr.data[2], attr.data[3]}
+		}
+		return
+	}
+	// tag is optional for other types
+	if len(attr.data) > 0 && attr.data[0] <= tagMax {
+		attr.tag = attr.data[0]
+		attr.tagd = true
+		attr.data = attr.data[1:]
+		attr.updateLen()
+	}
+}
+
+// join tag and data for tagged attr
+func (attr *Attr) joinTag() []byte {
+	if attr.atyp.Dtyp == zdict.TypeInt {
+		if len(attr.data) != 4 {
+			return attr.data
+		}
+		return []byte{attr.tag, attr.data[1], attr.data[2], attr.data[3]}
+	}
+	if !attr.tagd { // tag is not used
+		return attr.data
+	}
+	return append([]byte{attr.tag}, attr.data...)
+}
+
+// return attr data for sending, ra - request authenticator
+func (attr *Attr) wireData(pkt *Packet, ra []byte) ([]byte, error) {
+	if attr.atyp == nil {
+		return attr.data, nil
+	}
+	if attr.atyp.Enc != zdict.EncNone {
+		if !attr.dcr { // still encrypted
+			return attr.data, nil
+		}
+		switch attr.atyp.Enc {
+		case zdict.EncUsr:
+			return attr.encryptUsr(pkt, ra)
+		case zdict.EncTun:
+			return attr.encryptTun(pkt, ra)
+		}
+	}
+	if attr.atyp.Tag {
+		attr.updateLen()
+		return attr.joinTag(), nil
+	}
+	return attr.data, nil
+}
+
+// GetTag - return attr tag, 0 if attr is not tagged
+func (attr *Attr) GetTag() byte {
+	return attr.tag
+}
+
+// HasTag - attr has tag byte, tag value may be 0
+func (attr *Attr) HasTag() bool {
+	return attr.tagd
+}
+
+// GetName - return attr name from dictionary or attr number if attr is unknown
+func (attr *Attr) GetName() string {
+	return attr.name()
+}
+
+// GetType - return attr type
+func (attr *Attr) GetType() byte {
+	return attr.typ
+}
+
+// GetData - return raw attr data, data is encrypted if attr was not decrypted
 func (attr *Attr) GetData() []byte {
 	return attr.data
 }
 
-// GetEData - return evaluated attr data
+// GetValue - return evaluated attr data, error if data does not match dictionary type
+func (attr *Attr) GetValue(pkt *Packet) (interface{}, error) {
+	if attr.edata != nil {
+		return attr.edata, nil
+	}
+	if attr.atyp == nil {
+		attr.edata = attr.data
+		return attr.edata, nil
+	}
+	if err := attr.decrypt(pkt); err != nil {
+		return nil, fmt.Errorf("Attribute %s: %v", attr.atyp.Name, err)
+	}
+	v, err := evalData(attr.atyp.Dtyp, attr.data)
+	if err != nil {
+		return nil, fmt.Errorf("Attribute %s: %v", attr.atyp.Name, err)
+	}
+	attr.edata = v
+	return attr.edata, nil
+}
+
+// GetEData - return evaluated attr data, raw data if it does not match dictionary type,
+// nil if attr can't be decrypted, use GetEnum for value name
 func (attr *Attr) GetEData(pkt *Packet) interface{} {
-	if attr.edata == nil {
-		if attr.atyp == nil {
-			attr.edata = attr.data
-			return attr.edata
-		}
-		switch attr.atyp.Dtyp {
-		case zdict.TypeString:
-			if attr.atyp.Enc == zdict.EncUsr {
-				attr.decryptUsr(pkt)
-			}
-			attr.edata = string(attr.data)
-		case zdict.TypeInt:
-			if len(attr.data) == 4 {
-				attr.edata = binary.BigEndian.Uint32(attr.data)
-			}
-		case zdict.TypeIP4:
-			if len(attr.data) == 4 {
-				attr.edata = net.IPv4(attr.data[0], attr.data[1], attr.data[2], attr.data[3])
+	v, err := attr.GetValue(pkt)
+	if err != nil {
+		if attr.encrypted() {
+			return nil
+		}
+		return attr.data
+	}
+	return v
+}
+
+// GetEnum - return named value of attr, empty string if not found in packet dictionary
+func (attr *Attr) GetEnum(pkt *Packet) string {
+	var (
+		v  interface{}
+		vd *zdict.ValueData
+	)
+
+	if attr.atyp == nil {
+		return ""
+	}
+	v, _ = evalData(attr.atyp.Dtyp, attr.data)
+	switch t := v.(type) {
+	case uint32:
+		vd = pkt.GetDict().FindValueNum(attr.atyp, t)
+	case uint16:
+		vd = pkt.GetDict().FindValueNum(attr.atyp, uint32(t))
+	case uint8:
+		vd = pkt.GetDict().FindValueNum(attr.atyp, uint32(t))
+	}
+	if vd == nil {
+		return ""
+	}
+	return vd.Name
+}
+
+// encode extended attr with data val into buf (RFC 6929), return encoded len
+func (attr *Attr) encodeExt(buf, val []byte) (int, error) {
+	var (
+		hlen = 3 // type, len, Extended-Type
+		data = val
+		n, l int
+	)
+
+	if attr.ext == zdict.ExtEVS {
+		data = make([]byte, 5+len(val))
+		binary.BigEndian.PutUint32(data, attr.vid)
+		data[4] = byte(attr.vtyp)
+		copy(data[5:], val)
+	}
+	if !zdict.IsLongExt(attr.typ) {
+		if l = hlen + len(data); l > 255 {
+			return 0, fmt.Errorf("Extended attr %d.%d too long, len: %d", attr.typ, attr.ext, l)
+		}
+		if len(buf) < l {
+			return 0, fmt.Errorf("No space in buffer: left = %d", len(buf))
+		}
+		buf[0] = attr.typ
+		buf[1] = byte(l)
+		buf[2] = attr.ext
+		copy(buf[hlen:], data)
+		return l, nil
+	}
+	hlen++ // flags
+	for {
+		if l = len(data); l > 255-hlen {
+			l = 255 - hlen
+		}
+		if len(buf)-n < hlen+l {
+			return 0, fmt.Errorf("No space in buffer: left = %d", len(buf)-n)
+		}
+		buf[n] = attr.typ
+		buf[n+1] = byte(hlen + l)
+		buf[n+2] = attr.ext
+		buf[n+3] = 0
+		copy(buf[n+hlen:], data[:l])
+		if data = data[l:]; len(data) > 0 {
+			buf[n+3] = extFlagMore
+		}
+		n += hlen + l
+		if len(data) == 0 {
+			break
+		}
+	}
+	return n, nil
+}
+
+// vsaFormat - VendorType len, VendorLen len and continuation flag of VSA vendor, 1,1 for unknown vendor
+func vsaFormat(vend *zdict.VendorData) (tl, ll int, cont bool) {
+	if vend == nil {
+		return 1, 1, false
+	}
+	return vend.TypeLen, vend.LenLen, vend.Cont
+}
+
+// vsaHdrLen - len of vendor attr header: VendorType, VendorLen and continuation byte
+func vsaHdrLen(vend *zdict.VendorData) int {
+	tl, ll, cont := vsaFormat(vend)
+	if cont {
+		return tl + ll + 1
+	}
+	return tl + ll
+}
+
+// getUintN - get big endian uint of n bytes (1, 2 or 4)
+func getUintN(b []byte, n int) uint32 {
+	switch n {
+	case 1:
+		return uint32(b[0])
+	case 2:
+		return uint32(binary.BigEndian.Uint16(b))
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// putUintN - put big endian uint of n bytes (1, 2 or 4)
+func putUintN(b []byte, n int, v uint32) {
+	switch n {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		binary.BigEndian.PutUint16(b, uint16(v))
+	default:
+		binary.BigEndian.PutUint32(b, v)
+	}
+}
+
+// encodeVSA - encode VSA to buf using vendor format, WiMAX attr is split to fragments, returns encoded len
+func (attr *Attr) encodeVSA(buf, val []byte) (int, error) {
+	var (
+		tl, ll, cont = vsaFormat(attr.vend)
+		hlen         = 6 + vsaHdrLen(attr.vend) // type, len, VendorID and vendor header
+		n, l         int
+	)
+
+	if !cont && hlen+len(val) > 255 {
+		return 0, fmt.Errorf("Attr %s too long, len: %d", attr.name(), len(val))
+	}
+	for {
+		if l = len(val); l > 255-hlen {
+			l = 255 - hlen
+		}
+		if len(buf)-n < hlen+l {
+			return 0, fmt.Errorf("No space in buffer: left = %d", len(buf)-n)
+		}
+		buf[n] = zdict.AttrVSA
+		buf[n+1] = byte(hlen + l)
+		binary.BigEndian.PutUint32(buf[n+2:], attr.vid)
+		putUintN(buf[n+6:], tl, attr.vtyp)
+		if ll > 0 {
+			putUintN(buf[n+6+tl:], ll, uint32(hlen-6+l))
+		}
+		copy(buf[n+hlen:], val[:l])
+		val = val[l:]
+		if cont {
+			buf[n+6+tl+ll] = 0
+			if len(val) > 0 {
+				buf[n+6+tl+ll] = vsaFlagMore
 			}
 		}
-		if attr.edata == nil {
-			attr.edata = attr.data
+		n += hlen + l
+		if len(val) == 0 {
+			break
 		}
 	}
-	return attr.edata
+	return n, nil
 }
 
+// name - attr name for messages
+func (attr *Attr) name() string {
+	switch {
+	case attr.atyp != nil:
+		return attr.atyp.Name
+	case attr.typ == zdict.AttrVSA:
+		return fmt.Sprintf("%d.%d.%d", attr.typ, attr.vid, attr.vtyp)
+	case attr.ext == zdict.ExtEVS:
+		return fmt.Sprintf("%d.%d.%d.%d", attr.typ, attr.ext, attr.vid, attr.vtyp)
+	case attr.ext != 0:
+		return fmt.Sprintf("%d.%d", attr.typ, attr.ext)
+	}
+	return fmt.Sprintf("%d", attr.typ)
+}
+
+// maxLen - max data len of attr, 0 if attr can be split
+func (attr *Attr) maxLen() int {
+	var l int
+
+	switch {
+	case zdict.IsLongExt(attr.typ):
+		return 0
+	case zdict.IsExt(attr.typ):
+		l = 255 - 3
+		if attr.ext == zdict.ExtEVS {
+			l -= 5
+		}
+	case attr.typ == zdict.AttrVSA:
+		if _, _, cont := vsaFormat(attr.vend); cont {
+			return 0
+		}
+		l = 255 - 6 - vsaHdrLen(attr.vend)
+	case attr.atyp != nil && attr.atyp.Concat:
+		return 0
+	default:
+		l = 255 - 2
+	}
+	if attr.atyp != nil && attr.atyp.Tag && attr.atyp.Dtyp != zdict.TypeInt {
+		l-- // tag byte
+	}
+	return l
+}
+
+// setData - set attr data with len check
+func (attr *Attr) setData(val []byte) error {
+	if l := attr.maxLen(); l > 0 && len(val) > l {
+		return fmt.Errorf("Attr %s too long, len: %d, max: %d", attr.name(), len(val), l)
+	}
+	attr.data = val
+	attr.updateLen()
+	return nil
+}
+
+// updateLen - update attr len fields, len is 255 for attr longer than one TLV
 func (attr *Attr) updateLen() {
-	if attr.typ == zdict.AttrVSA {
-		attr.vlen = byte(len(attr.data) + 2)
-		attr.len = attr.vlen + 6
-	} else {
-		attr.vlen = 0
-		attr.len = byte(len(attr.data) + 2)
+	dl := len(attr.data)
+	if attr.tagd && attr.atyp != nil && attr.atyp.Dtyp != zdict.TypeInt && attr.atyp.Enc != zdict.EncTun {
+		dl++ // tag byte
+	}
+	l := dl + 2
+	attr.vlen = 0
+	switch {
+	case attr.typ == zdict.AttrVSA:
+		vl := dl + vsaHdrLen(attr.vend)
+		if _, ll, _ := vsaFormat(attr.vend); ll > 0 && vl <= 255 {
+			attr.vlen = byte(vl)
+		} else if ll > 0 {
+			attr.vlen = 255
+		}
+		l += 4 + vl - dl
+	case zdict.IsExt(attr.typ):
+		l++ // Extended-Type
+		if zdict.IsLongExt(attr.typ) {
+			l++ // flags
+		}
+		if attr.ext == zdict.ExtEVS {
+			l += 5 // VendorID and VendorType
+		}
+	}
+	if l > 255 {
+		l = 255
 	}
+	attr.len = byte(l)
 }
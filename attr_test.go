@@ -0,0 +1,280 @@
+package zradius
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// RFC 2865 §7.1 example values
+var (
+	testSecret  = []byte("xyzzy5461")
+	testReqAuth = mustHex("0f403f9473978057bd83d5cb98f4227a")
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// testRequest - Access-Request with RFC 2865 §7.1 authenticator and secret
+func testRequest() *Packet {
+	req := &Packet{code: zdict.AccessRequest, id: 0, secret: testSecret}
+	copy(req.auth[:], testReqAuth)
+	return req
+}
+
+// testAttr - attr with dictionary entry and raw data
+func testAttr(name string, data []byte) *Attr {
+	ad := zdict.Default.FindAttrName(name)
+	return &Attr{typ: ad.Typ, atyp: ad, data: data}
+}
+
+func TestTunPassKnownAnswer(t *testing.T) {
+	req := testRequest()
+	rep := req.RadReply(zdict.AccessAccept)
+	attr := testAttr("Tunnel-Password", mustHex("018001cd3ba3160b74f387a6755c09e6838723"))
+	if err := attr.decryptTun(rep); err != nil {
+		t.Fatal(err)
+	}
+	if string(attr.data) != "tunnelpass" || attr.tag != 1 || !attr.dcr {
+		t.Fatalf("got %q, tag %d", attr.data, attr.tag)
+	}
+}
+
+func TestTunPassRoundTrip(t *testing.T) {
+	req := testRequest()
+	for _, pw := range []string{"", "a", "0123456789abcde", "0123456789abcdef", string(make([]byte, tunMaxPass))} {
+		rep := req.RadReply(zdict.AccessAccept)
+		if err := rep.AddAttrStrTagged("Tunnel-Password", 2, pw); err != nil {
+			t.Fatal(err)
+		}
+		if err := rep.Encode(false); err != nil {
+			t.Fatal(err)
+		}
+		if rep.data[MinPLen+3]&0x80 == 0 {
+			t.Fatal("salt high bit is not set")
+		}
+		got := &Packet{data: rep.data, secret: testSecret}
+		if err := got.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		got.SetRequest(req)
+		a := got.GetAttr("Tunnel-Password")
+		if v := a.GetEData(got); v != pw || a.GetTag() != 2 {
+			t.Fatalf("len %d: got %q, tag %d", len(pw), v, a.GetTag())
+		}
+	}
+	rep := req.RadReply(zdict.AccessAccept)
+	rep.MustAddAttrStr("Tunnel-Password", string(make([]byte, tunMaxPass+1)))
+	if rep.Encode(false) == nil {
+		t.Fatal("too long password is encoded")
+	}
+}
+
+func TestTunPassDecryptError(t *testing.T) {
+	req := testRequest()
+	rep := req.RadReply(zdict.AccessAccept)
+	good := mustHex("018001cd3ba3160b74f387a6755c09e6838723")
+	for name, data := range map[string][]byte{
+		"short":    good[:10],
+		"not16":    good[:len(good)-1],
+		"salt":     append([]byte{1, 0x00, 0x01}, good[3:]...),
+		"pass len": append(append([]byte(nil), good[:3]...), make([]byte, 16)...),
+	} {
+		attr := testAttr("Tunnel-Password", data)
+		if err := attr.decryptTun(rep); err == nil {
+			t.Fatalf("%s: no error", name)
+		}
+		if attr.dcr || !bytes.Equal(attr.data, data) {
+			t.Fatalf("%s: attr is changed on error", name)
+		}
+	}
+	// reply without request can't be decrypted
+	noreq := &Packet{code: zdict.AccessAccept, secret: testSecret}
+	attr := testAttr("Tunnel-Password", good)
+	if err := attr.decryptTun(noreq); err != ErrNoReq {
+		t.Fatal(err)
+	}
+	if attr.GetEData(noreq) != nil {
+		t.Fatal("encrypted data is returned as value")
+	}
+	// still encrypted attr is sent as is
+	if v, err := attr.wireData(noreq, testReqAuth); err != nil || !bytes.Equal(v, good) {
+		t.Fatal("encrypted attr is changed on encode")
+	}
+}
+
+func TestUsrPassKnownAnswer(t *testing.T) {
+	req := testRequest()
+	attr := testAttr("User-Password", []byte("arctangent"))
+	enc, err := attr.encryptUsr(req, testReqAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustHex("0dbe708d93d413ce3196e43f782a0aee"); !bytes.Equal(enc, want) {
+		t.Fatalf("got %x, want %x", enc, want)
+	}
+	attr = testAttr("User-Password", enc)
+	if err = attr.decryptUsr(req); err != nil {
+		t.Fatal(err)
+	}
+	if string(attr.data) != "arctangent" || !attr.dcr {
+		t.Fatalf("got %q", attr.data)
+	}
+}
+
+func TestUsrPassRoundTrip(t *testing.T) {
+	for _, pw := range []string{"", "a", "0123456789abcdef", "0123456789abcdef0", string(bytes.Repeat([]byte{'x'}, usrMaxPass))} {
+		req := RadNew(zdict.AccessRequest)
+		req.SetSecret(testSecret)
+		req.MustAddAttrStr("User-Password", pw)
+		if err := req.Encode(true); err != nil {
+			t.Fatal(err)
+		}
+		if l := int(req.data[MinPLen+1]) - 2; l == 0 || l%16 != 0 || (len(pw) > 3 && bytes.Contains(req.data, []byte(pw))) {
+			t.Fatalf("len %d: password is not hidden", len(pw))
+		}
+		got := &Packet{data: req.data, secret: testSecret}
+		if err := got.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		if v := got.GetAttr("User-Password").GetEData(got); v != pw {
+			t.Fatalf("len %d: got %q", len(pw), v)
+		}
+	}
+	req := RadNew(zdict.AccessRequest)
+	req.MustAddAttrStr("User-Password", string(make([]byte, usrMaxPass+1)))
+	if req.Encode(true) == nil {
+		t.Fatal("too long password is encoded")
+	}
+}
+
+func TestUsrPassDecryptError(t *testing.T) {
+	req := testRequest()
+	for _, data := range [][]byte{nil, make([]byte, 15), make([]byte, 17)} {
+		attr := testAttr("User-Password", data)
+		if err := attr.decryptUsr(req); err == nil || attr.dcr {
+			t.Fatalf("len %d: no error", len(data))
+		}
+		if attr.GetEData(req) != nil {
+			t.Fatalf("len %d: encrypted data is returned as value", len(data))
+		}
+	}
+	attr := testAttr("User-Password", mustHex("0dbe708d93d413ce3196e43f782a0aee"))
+	noauth := &Packet{code: zdict.AccessRequest, secret: testSecret}
+	if err := attr.decryptUsr(noauth); err == nil || attr.dcr {
+		t.Fatal("decrypted without authenticator")
+	}
+	// still encrypted attr is not encrypted again
+	if v, err := attr.wireData(req, testReqAuth); err != nil || !bytes.Equal(v, attr.data) {
+		t.Fatal("encrypted attr is changed on encode")
+	}
+}
+
+func TestTagged(t *testing.T) {
+	req := testRequest()
+	rep := req.RadReply(zdict.AccessAccept)
+	rep.MustAddAttrIntTagged("Tunnel-Type", 1, 3)
+	rep.MustAddAttrEnumTagged("Tunnel-Type", 2, "VLAN")
+	rep.MustAddAttrStrTagged("Tunnel-Private-Group-Id", 2, "100")
+	rep.MustAddAttrStr("Tunnel-Server-Endpoint", "1.2.3.4") // no tag
+	rep.MustAddAttrStrTagged("Tunnel-Password", 2, "pw")
+	if err := rep.Encode(false); err != nil {
+		t.Fatal(err)
+	}
+	r := testDecode(t, rep.data)
+	r.SetRequest(req)
+	for i, want := range []struct {
+		tag    byte
+		hasTag bool
+		val    interface{}
+	}{
+		{1, true, uint32(3)},
+		{2, true, uint32(13)},
+		{2, true, "100"},
+		{0, false, "1.2.3.4"},
+		{2, true, "pw"},
+	} {
+		a := r.attr[i]
+		if v := a.GetEData(r); a.GetTag() != want.tag || a.HasTag() != want.hasTag || v != want.val {
+			t.Fatalf("attr %d: tag %d/%v, value %v", i, a.GetTag(), a.HasTag(), v)
+		}
+	}
+	if rep.AddAttrIntTagged("User-Name", 1, 1) == nil {
+		t.Fatal("tag is added to not tagged attr")
+	}
+	if rep.AddAttrIntTagged("Tunnel-Type", tagMax+1, 1) == nil || rep.AddAttrIntTagged("Tunnel-Type", 1, 0x1000000) == nil {
+		t.Fatal("value out of range is added")
+	}
+}
+
+func TestTagZero(t *testing.T) {
+	// Tunnel-Private-Group-Id with tag 0 and Tunnel-Type with tag 0
+	wire := []byte{81, 6, 0, '1', '0', '0', 64, 6, 0, 0, 0, 13}
+	data := append([]byte{zdict.AccessAccept, 1, 0, byte(MinPLen + len(wire))}, make([]byte, 16)...)
+	data = append(data, wire...)
+	r := testDecode(t, data)
+	a := r.attr[0]
+	if !a.HasTag() || a.GetTag() != 0 || string(a.GetData()) != "100" || a.len != 6 {
+		t.Fatalf("tag %d/%v, data %q, len %d", a.GetTag(), a.HasTag(), a.GetData(), a.len)
+	}
+	if v := r.attr[1].GetEData(r); !r.attr[1].HasTag() || v != uint32(13) {
+		t.Fatalf("Tunnel-Type %v", v)
+	}
+	if err := r.Encode(false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r.data[MinPLen:], wire) {
+		t.Fatalf("got %x, want %x", r.data[MinPLen:], wire)
+	}
+	// tag 0 added explicitly is sent, len includes tag byte
+	rep := RadNew(zdict.AccessAccept)
+	rep.MustAddAttrStrTagged("Tunnel-Private-Group-Id", 0, "100")
+	if a = rep.attr[0]; a.len != 6 {
+		t.Fatalf("len %d", a.len)
+	}
+	rep.Encode(false)
+	if !bytes.Equal(rep.data[MinPLen:], wire[:6]) {
+		t.Fatalf("got %x", rep.data[MinPLen:])
+	}
+	// string without tag byte stays untouched
+	rep = RadNew(zdict.AccessAccept)
+	rep.MustAddAttrStr("Tunnel-Private-Group-Id", "100")
+	rep.Encode(false)
+	if r = testDecode(t, rep.data); r.attr[0].HasTag() || string(r.attr[0].GetData()) != "100" {
+		t.Fatal("tag is found in untagged attr")
+	}
+}
+
+func TestEnum(t *testing.T) {
+	pkt := RadNew(zdict.CoARequest)
+	pkt.MustAddAttrEnum("Service-Type", "authorize-only")
+	pkt.MustAddAttrEnum("Acct-Status-Type", "Interim-Update")
+	pkt.MustAddAttr("NAS-Port-Type", "Ethernet")
+	pkt.MustAddAttr("Acct-Terminate-Cause", 1000) // no name for value
+	if pkt.AddAttrEnum("Acct-Status-Type", "No-Such-Value") == nil || pkt.AddAttrEnum("User-Name", "X") == nil {
+		t.Fatal("unknown value is added")
+	}
+	if err := pkt.Encode(true); err != nil {
+		t.Fatal(err)
+	}
+	r := testDecode(t, pkt.data)
+	for i, want := range []struct {
+		val  uint32
+		name string
+	}{{17, "Authorize-Only"}, {3, "Interim-Update"}, {15, "Ethernet"}, {1000, ""}} {
+		if v := r.attr[i].GetEData(r); v != want.val || r.attr[i].GetEnum(r) != want.name {
+			t.Fatalf("%s: got %v %q, want %d %q", r.attr[i].GetName(), v, r.attr[i].GetEnum(r), want.val, want.name)
+		}
+	}
+	if s := r.String(); !strings.Contains(s, "Service-Type, L: 6, DATA: Authorize-Only (17)") {
+		t.Fatal(s)
+	}
+}
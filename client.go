@@ -0,0 +1,289 @@
+package zradius
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// RadClient errors
+var (
+	ErrTimeout      = errors.New("No reply from server")
+	ErrClientClosed = errors.New("Client closed")
+)
+
+// Retransmission defaults (RFC 5080 §2.2.1)
+const (
+	DefIRT = 2 * time.Second  // initial retransmission timeout
+	DefMRT = 16 * time.Second // max retransmission timeout
+	DefMRC = 5                // max retransmission count
+	DefMRD = 30 * time.Second // max retransmission duration
+)
+
+// reader pause after socket error, so persistent error is not a busy loop
+const readBackoff = 10 * time.Millisecond
+
+// RadClient - Radius client for one server, owns connected UDP socket, allocates IDs for it
+// so no more than 256 requests are outstanding, matches and verifies replies and retransmits requests
+type RadClient struct {
+	IRT     time.Duration     // initial retransmission timeout, DefIRT if not set
+	MRT     time.Duration     // max retransmission timeout, DefMRT if not set
+	MRC     int               // max retransmission count, DefMRC if 0, no retransmissions if < 0
+	MRD     time.Duration     // max retransmission duration, DefMRD if 0, not limited if < 0
+	Dict    *zdict.Dictionary // dictionary for replies, zdict.Default if nil
+	addr    *net.UDPAddr      // server addr
+	secret  []byte            // shared secret
+	conn    *net.UDPConn      // connected socket
+	sem     chan struct{}     // outstanding requests limit
+	mu      sync.Mutex        // pending lock
+	pending [256]*clientReq   // outstanding requests by ID
+	next    byte              // next ID to try
+	done    chan struct{}     // closed by Close
+	once    sync.Once         // Close once
+	wg      sync.WaitGroup    // reader
+}
+
+// outstanding request
+type clientReq struct {
+	pkt   *Packet      // sent request
+	ch    chan *Packet // verified reply
+	ready bool         // pkt is encoded and not changed anymore, set under RadClient.mu
+}
+
+// NewRadClient - create client for server addr ("host:port") with shared secret
+func NewRadClient(addr string, secret []byte) (*RadClient, error) {
+	ua, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, ua)
+	if err != nil {
+		return nil, err
+	}
+	c := &RadClient{
+		addr:   ua,
+		secret: secret,
+		conn:   conn,
+		sem:    make(chan struct{}, 256),
+		done:   make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go c.reader()
+	return c, nil
+}
+
+// GetAddr - get server addr
+func (c *RadClient) GetAddr() *net.UDPAddr {
+	return c.addr
+}
+
+// Close - close socket, outstanding requests fail with ErrClientClosed
+func (c *RadClient) Close() error {
+	var err error
+
+	c.once.Do(func() {
+		close(c.done)
+		err = c.conn.Close()
+		c.wg.Wait()
+	})
+	return err
+}
+
+// reader - receive replies and pass verified ones to waiting requests
+func (c *RadClient) reader() {
+	defer c.wg.Done()
+	buf := make([]byte, MaxPLen)
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			select {
+			case <-c.done:
+				return
+			case <-time.After(readBackoff): // ICMP errors and etc.
+				continue
+			}
+		}
+		if n < MinPLen {
+			continue
+		}
+		c.mu.Lock()
+		r := c.pending[buf[1]]
+		c.mu.Unlock()
+		if r == nil || !r.ready {
+			continue
+		}
+		pl := int(buf[2])<<8 | int(buf[3])
+		if pl < MinPLen || pl > n {
+			continue
+		}
+		rep := &Packet{
+			conn: c.conn,
+			addr: c.addr,
+			len:  uint16(pl),
+			rlen: n,
+			data: append([]byte(nil), buf[:pl]...),
+			dict: c.Dict,
+		}
+		rep.secret = r.pkt.secret // request may have own secret
+		if rep.VerifyReply(r.pkt, rep.secret) != nil || rep.Decode() != nil {
+			continue
+		}
+		select {
+		case r.ch <- rep:
+		default: // duplicate reply
+		}
+	}
+}
+
+// acquire - allocate ID for request, ErrTimeout is returned if md fires while all IDs are in use
+func (c *RadClient) acquire(ctx context.Context, md <-chan time.Time, r *clientReq) error {
+	select {
+	case <-c.done: // free ID may be selected below after Close
+		return ErrClientClosed
+	default:
+	}
+	select {
+	case c.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-md:
+		return ErrTimeout
+	case <-c.done:
+		return ErrClientClosed
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.pending[c.next] != nil { // free ID exists while sem is acquired
+		c.next++
+	}
+	r.pkt.id = c.next
+	c.pending[c.next] = r
+	c.next++
+	return nil
+}
+
+// release - free request ID
+func (c *RadClient) release(r *clientReq) {
+	c.mu.Lock()
+	c.pending[r.pkt.id] = nil
+	c.mu.Unlock()
+	<-c.sem
+}
+
+// nextRT - next retransmission timeout: RT = 2*RTprev + RAND*RTprev, RAND is -0.1..+0.1, limited by MRT
+func nextRT(rt, mrt time.Duration) time.Duration {
+	rt = 2*rt + time.Duration((rand.Float64()*0.2-0.1)*float64(rt))
+	if rt > mrt {
+		rt = mrt + time.Duration((rand.Float64()*0.2-0.1)*float64(mrt))
+	}
+	return rt
+}
+
+// Exchange - send request and wait for verified reply, request is retransmitted with exponential backoff,
+// ErrTimeout is returned if there is no reply after MRC retransmissions or MRD, ctx error if ctx is done,
+// request is not changed: copy is sent with ID allocated by client and client secret if request has no secret
+func (c *RadClient) Exchange(ctx context.Context, pkt *Packet) (*Packet, error) {
+	rep, _, err := c.exchange(ctx, pkt, true)
+	return rep, err
+}
+
+// Status - send Status-Server (RFC 5997) and wait for reply until ctx is done or MRD is passed (ErrTimeout),
+// Status-Server is not retransmitted, returns reply and round trip time
+func (c *RadClient) Status(ctx context.Context) (*Packet, time.Duration, error) {
+	req := RadNew(zdict.StatusServer)
+	req.SetDict(c.Dict)
+	req.AddMsgAuth()
+	return c.exchange(ctx, req, false)
+}
+
+// copyReq - copy request with attrs, attrs are changed by Encode
+func copyReq(pkt *Packet) *Packet {
+	req := *pkt
+	req.attr = make([]*Attr, len(pkt.attr))
+	for i, a := range pkt.attr {
+		ac := *a
+		req.attr[i] = &ac
+	}
+	return &req
+}
+
+// exchange - send request and wait for reply, returns reply and time from last transmission
+func (c *RadClient) exchange(ctx context.Context, pkt *Packet, retrans bool) (*Packet, time.Duration, error) {
+	var (
+		tc   <-chan time.Time // retransmission timer
+		md   <-chan time.Time // max retransmission duration timer
+		sent time.Time        // last transmission time
+	)
+
+	irt, mrt, mrc, mrd := c.IRT, c.MRT, c.MRC, c.MRD
+	if irt <= 0 {
+		irt = DefIRT
+	}
+	if mrt <= 0 {
+		mrt = DefMRT
+	}
+	if mrc == 0 {
+		mrc = DefMRC
+	}
+	if mrd == 0 {
+		mrd = DefMRD
+	}
+	if mrd > 0 { // own timer, so MRD is ErrTimeout and ctx error is caller's one
+		d := time.NewTimer(mrd)
+		defer d.Stop()
+		md = d.C
+	}
+	req := copyReq(pkt) // caller's request is not changed
+	r := &clientReq{
+		pkt: req,
+		ch:  make(chan *Packet, 1),
+	}
+	if err := c.acquire(ctx, md, r); err != nil {
+		return nil, 0, err
+	}
+	defer c.release(r)
+	if req.secret == nil {
+		req.secret = c.secret
+	}
+	req.conn = c.conn
+	req.addr = c.addr
+	if err := req.Encode(true); err != nil {
+		return nil, 0, err
+	}
+	c.mu.Lock()
+	r.ready = true // replies are verified from now
+	c.mu.Unlock()
+	rt := irt + time.Duration((rand.Float64()*0.2-0.1)*float64(irt)) // RT = IRT + RAND*IRT
+	t := time.NewTimer(rt)
+	defer t.Stop()
+	if retrans {
+		tc = t.C
+	}
+	for n := 0; ; n++ {
+		sent = time.Now()
+		if err := req.SendConn(); err != nil {
+			return nil, 0, err
+		}
+		select {
+		case rep := <-r.ch:
+			return rep, time.Since(sent), nil
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-md:
+			return nil, 0, ErrTimeout
+		case <-c.done:
+			return nil, 0, ErrClientClosed
+		case <-tc:
+		}
+		if mrc < 0 || n >= mrc {
+			return nil, 0, ErrTimeout
+		}
+		rt = nextRT(rt, mrt)
+		t.Reset(rt)
+	}
+}
@@ -0,0 +1,225 @@
+package zradius
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// testUDPServer - raw UDP server, f is called for each received request and returns reply or nil
+func testUDPServer(t *testing.T, f func(req *Packet) *Packet) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			req, err := RadRecv(conn)
+			if err != nil {
+				return
+			}
+			req.secret = testSecret
+			if req.Decode() != nil {
+				continue
+			}
+			if rep := f(req); rep != nil && rep.Encode(false) == nil {
+				rep.Send()
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		conn.Close()
+		<-done
+	})
+	return conn
+}
+
+func TestClientExchange(t *testing.T) {
+	conn := testUDPServer(t, echoHandler)
+	c := testClient(t, conn.LocalAddr())
+	req := RadNew(zdict.AccessRequest)
+	req.MustAddAttrStr("User-Name", "bob")
+	req.MustAddAttrStr("User-Password", "secret")
+	orig := *req
+	for i := 0; i < 2; i++ { // request is not changed and can be sent again
+		rep, err := c.Exchange(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rep.GetCode() != zdict.AccessAccept || string(rep.GetAttr("User-Name").GetData()) != "bob" {
+			t.Fatalf("got %s", rep)
+		}
+		if req.id != orig.id || req.auth != orig.auth || req.data != nil || req.secret != nil || req.conn != nil {
+			t.Fatal("request is changed by Exchange")
+		}
+	}
+}
+
+func TestClientSecret(t *testing.T) {
+	other := []byte("other")
+	conn := testUDPServer(t, func(req *Packet) *Packet { // server has other secret for client
+		rep := req.RadReply(zdict.AccessAccept)
+		rep.SetSecret(other)
+		return rep
+	})
+	c := testClient(t, conn.LocalAddr())
+	c.MRC = -1
+	c.MRD = 200 * time.Millisecond
+	req := RadNew(zdict.AccessRequest)
+	req.SetSecret(other)
+	rep, err := c.Exchange(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.GetCode() != zdict.AccessAccept || string(rep.secret) != "other" {
+		t.Fatalf("got %s, secret %q", rep, rep.secret)
+	}
+	// reply is verified with client secret for request without secret
+	if _, err = c.Exchange(context.Background(), RadNew(zdict.AccessRequest)); err != ErrTimeout {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestClientConcurrent(t *testing.T) {
+	const n = 20
+
+	// replies with all IDs are sent before real one, so reader is busy while requests are encoded
+	conn := testUDPServer(t, func(req *Packet) *Packet {
+		for id := 0; id < 256; id++ {
+			if byte(id) == req.id {
+				continue
+			}
+			rep := req.RadReply(zdict.AccessReject) // authenticator of other request does not match
+			rep.id = byte(id)
+			if rep.Encode(false) == nil {
+				rep.Send()
+			}
+		}
+		return echoHandler(req)
+	})
+	c := testClient(t, conn.LocalAddr())
+	req := RadNew(zdict.AccessRequest) // shared by all Exchange calls, tagged attr is changed by Encode
+	req.MustAddAttrStr("User-Name", "bob")
+	req.MustAddAttrStr("User-Password", "secret")
+	req.MustAddAttrIntTagged("Tunnel-Type", 1, 3)
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			rep, err := c.Exchange(context.Background(), req)
+			if err == nil && rep.GetCode() != zdict.AccessAccept {
+				err = fmt.Errorf("got %s", rep)
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestClientRetransmit(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+
+	conn := testUDPServer(t, func(req *Packet) *Packet {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, fmt.Sprintf("%d/%x", req.id, req.auth))
+		if len(seen) < 3 { // first transmissions are lost
+			return nil
+		}
+		return req.RadReply(zdict.AccessAccept)
+	})
+	c := testClient(t, conn.LocalAddr())
+	if _, err := c.Exchange(context.Background(), RadNew(zdict.AccessRequest)); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 3 || seen[0] != seen[1] || seen[1] != seen[2] {
+		t.Fatalf("retransmissions: %v", seen)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	conn := testUDPServer(t, func(*Packet) *Packet { return nil }) // blackhole
+	c := testClient(t, conn.LocalAddr())
+	c.IRT = 20 * time.Millisecond
+	c.MRT = 20 * time.Millisecond
+	// MRC is reached
+	c.MRC = 2
+	if _, err := c.Exchange(context.Background(), RadNew(zdict.AccessRequest)); err != ErrTimeout {
+		t.Fatalf("MRC: got %v", err)
+	}
+	// MRD is reached
+	c.MRC = 1000
+	c.MRD = 100 * time.Millisecond
+	t0 := time.Now()
+	if _, err := c.Exchange(context.Background(), RadNew(zdict.AccessRequest)); err != ErrTimeout {
+		t.Fatalf("MRD: got %v", err)
+	}
+	if d := time.Since(t0); d < c.MRD || d > 10*c.MRD {
+		t.Fatalf("MRD: returned after %v", d)
+	}
+	if _, _, err := c.Status(context.Background()); err != ErrTimeout {
+		t.Fatalf("Status: got %v", err)
+	}
+	// caller deadline and cancel are not ErrTimeout
+	c.MRD = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Exchange(ctx, RadNew(zdict.AccessRequest)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("deadline: got %v", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := c.Exchange(ctx, RadNew(zdict.AccessRequest)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancel: got %v", err)
+	}
+	c.Close()
+	if _, err := c.Exchange(context.Background(), RadNew(zdict.AccessRequest)); err != ErrClientClosed {
+		t.Fatalf("closed: got %v", err)
+	}
+}
+
+func TestClientIDs(t *testing.T) {
+	const n = 600 // more than IDs available
+
+	conn := testUDPServer(t, func(req *Packet) *Packet {
+		time.Sleep(time.Millisecond)
+		return echoHandler(req)
+	})
+	c := testClient(t, conn.LocalAddr())
+	c.MRC = 1000
+	c.MRD = 10 * time.Second
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(name string) {
+			req := RadNew(zdict.AccessRequest)
+			req.MustAddAttrStr("User-Name", name)
+			rep, err := c.Exchange(context.Background(), req)
+			if err == nil && string(rep.GetAttr("User-Name").GetData()) != name {
+				err = fmt.Errorf("reply for %s is matched to %s", rep.GetAttr("User-Name").GetData(), name)
+			}
+			errs <- err
+		}(fmt.Sprintf("user%d", i))
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
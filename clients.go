@@ -0,0 +1,296 @@
+package zradius
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// Clients registry errors
+var (
+	ErrCodeDenied = errors.New("Packet code is not allowed for client")
+)
+
+// NAS - Radius client (NAS) parameters in Clients registry
+type NAS struct {
+	Prefix         netip.Prefix // client source addresses
+	Secret         []byte       // shared secret
+	ShortName      string       // client name for logs
+	RequireMsgAuth bool         // require Message-Authenticator in Access-Request
+	Codes          []byte       // allowed packet codes, all codes are allowed if empty
+	NASType        string       // NAS type, not used by zradius
+}
+
+// Allowed - packet code is allowed for client
+func (c *NAS) Allowed(code byte) bool {
+	if len(c.Codes) == 0 {
+		return true
+	}
+	for _, v := range c.Codes {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Clients - Radius clients registry, client is found by longest prefix match of source addr
+type Clients struct {
+	mu   sync.RWMutex
+	list []*NAS            // sorted by prefix len, longest first
+	path string            // file for Reload
+	sum  [sha256.Size]byte // file content hash on last load
+}
+
+// NewClients - create empty registry
+func NewClients() *Clients {
+	return &Clients{}
+}
+
+// sortClients - sort by prefix len, longest first
+func sortClients(list []*NAS) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Prefix.Bits() > list[j].Prefix.Bits()
+	})
+}
+
+// Add - add client, client with the same prefix is replaced
+func (cs *Clients) Add(c *NAS) error {
+	if !c.Prefix.IsValid() {
+		return fmt.Errorf("Bad client prefix %s", c.Prefix)
+	}
+	if len(c.Secret) == 0 {
+		return fmt.Errorf("Empty secret for client %s", c.Prefix)
+	}
+	c.Prefix = c.Prefix.Masked()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	list := make([]*NAS, 0, len(cs.list)+1)
+	for _, v := range cs.list {
+		if v.Prefix != c.Prefix {
+			list = append(list, v)
+		}
+	}
+	list = append(list, c)
+	sortClients(list)
+	cs.list = list
+	return nil
+}
+
+// Del - delete client by prefix
+func (cs *Clients) Del(p netip.Prefix) {
+	p = p.Masked()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	list := make([]*NAS, 0, len(cs.list))
+	for _, v := range cs.list {
+		if v.Prefix != p {
+			list = append(list, v)
+		}
+	}
+	cs.list = list
+}
+
+// Find - find client by addr, nil if not found
+func (cs *Clients) Find(ip netip.Addr) *NAS {
+	ip = ip.Unmap()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	for _, c := range cs.list {
+		if c.Prefix.Contains(ip) {
+			return c
+		}
+	}
+	return nil
+}
+
+// FindAddr - find client by UDP addr, nil if not found
+func (cs *Clients) FindAddr(addr *net.UDPAddr) *NAS {
+	if addr == nil {
+		return nil
+	}
+	ip, ok := netip.AddrFromSlice(addr.IP)
+	if !ok {
+		return nil
+	}
+	return cs.Find(ip)
+}
+
+// Secret - shared secret for addr, can be used as Server SecretFunc
+func (cs *Clients) Secret(addr *net.UDPAddr) []byte {
+	if c := cs.FindAddr(addr); c != nil {
+		return c.Secret
+	}
+	return nil
+}
+
+// Check - find client for received packet, check client policy and set packet secret,
+// packet must be dropped on error
+func (cs *Clients) Check(pkt *Packet) error {
+	if len(pkt.data) < MinPLen {
+		return ErrPktLen
+	}
+	c := cs.FindAddr(pkt.addr)
+	if c == nil {
+		return ErrUnknownClient
+	}
+	if !c.Allowed(pkt.data[0]) {
+		return ErrCodeDenied
+	}
+	if c.RequireMsgAuth && pkt.data[0] == zdict.AccessRequest { // Status-Server always requires it
+		if err := pkt.VerifyMsgAuth(c.Secret, true); err != nil {
+			return err
+		}
+	}
+	pkt.secret = c.Secret
+	pkt.client = c
+	return nil
+}
+
+// parse client line: prefix secret [name=value ...]
+func parseClient(s string) (*NAS, error) {
+	var err error
+
+	f := strings.Fields(s)
+	if len(f) < 2 {
+		return nil, fmt.Errorf("Client needs prefix and secret")
+	}
+	c := &NAS{Secret: []byte(f[1])}
+	if strings.IndexByte(f[0], '/') < 0 {
+		var ip netip.Addr
+		if ip, err = netip.ParseAddr(f[0]); err != nil {
+			return nil, err
+		}
+		c.Prefix = netip.PrefixFrom(ip, ip.BitLen())
+	} else if c.Prefix, err = netip.ParsePrefix(f[0]); err != nil {
+		return nil, err
+	}
+	for _, o := range f[2:] {
+		i := strings.IndexByte(o, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("Bad client option %s", o)
+		}
+		switch v := o[i+1:]; o[:i] {
+		case "shortname":
+			c.ShortName = v
+		case "nas_type":
+			c.NASType = v
+		case "require_message_authenticator":
+			if c.RequireMsgAuth, err = strconv.ParseBool(v); err != nil {
+				return nil, fmt.Errorf("Bad client option %s", o)
+			}
+		case "codes":
+			for _, n := range strings.Split(v, ",") {
+				var code uint64
+				if code, err = strconv.ParseUint(n, 10, 8); err != nil {
+					return nil, fmt.Errorf("Bad packet code %s", n)
+				}
+				c.Codes = append(c.Codes, byte(code))
+			}
+		default:
+			return nil, fmt.Errorf("Unknown client option %s", o)
+		}
+	}
+	return c, nil
+}
+
+// parseClients - parse clients file data, lines are "prefix secret [name=value ...]", # starts comment
+func parseClients(path string, data []byte) ([]*NAS, error) {
+	var (
+		list []*NAS
+		line int
+	)
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line++
+		s := sc.Text()
+		if i := strings.IndexByte(s, '#'); i >= 0 {
+			s = s[:i]
+		}
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		c, err := parseClient(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		c.Prefix = c.Prefix.Masked()
+		list = append(list, c)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	sortClients(list)
+	return list, nil
+}
+
+// load - read clients file, registry is replaced if force is set or file content is changed
+func (cs *Clients) load(path string, force bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	cs.mu.RLock()
+	same := cs.path == path && cs.sum == sum
+	cs.mu.RUnlock()
+	if same && !force {
+		return nil
+	}
+	list, err := parseClients(path, data)
+	if err != nil {
+		return err
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.list = list
+	cs.path = path
+	cs.sum = sum
+	return nil
+}
+
+// LoadFile - replace all clients with clients from file, registry is not changed on error
+func (cs *Clients) LoadFile(path string) error {
+	return cs.load(path, true)
+}
+
+// Reload - load clients file again if its content was changed since last load
+func (cs *Clients) Reload() error {
+	cs.mu.RLock()
+	path := cs.path
+	cs.mu.RUnlock()
+	if path == "" {
+		return fmt.Errorf("Clients file is not loaded")
+	}
+	return cs.load(path, false)
+}
+
+// Watch - call Reload every interval until ctx is done, reload errors are passed to errf if set
+func (cs *Clients) Watch(ctx context.Context, interval time.Duration, errf func(err error)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := cs.Reload(); err != nil && errf != nil {
+				errf(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package zradius
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// testClients - registry loaded from clients file text, returns file path
+func testClients(t *testing.T, text string) (*Clients, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "clients")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cs := NewClients()
+	if err := cs.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	return cs, path
+}
+
+func TestClientsFind(t *testing.T) {
+	cs, _ := testClients(t, `# test clients
+10.0.0.0/8     net8   shortname=wide
+10.1.0.0/16    net16  shortname=narrow nas_type=cisco
+10.1.2.3       host   require_message_authenticator=1 codes=4
+2001:db8::/32  net6
+`)
+	for addr, want := range map[string]string{
+		"10.9.9.9":         "net8",
+		"10.1.9.9":         "net16",
+		"10.1.2.3":         "host",
+		"::ffff:10.1.2.4":  "net16",
+		"2001:db8:1::1":    "net6",
+		"192.168.0.1":      "",
+		"2001:db9:1::1":    "",
+		"::ffff:192.0.2.1": "",
+	} {
+		ua := net.UDPAddrFromAddrPort(netip.AddrPortFrom(netip.MustParseAddr(addr), 1812))
+		if got := string(cs.Secret(ua)); got != want {
+			t.Fatalf("%s: got %q, want %q", addr, got, want)
+		}
+	}
+	c := cs.Find(netip.MustParseAddr("10.1.2.3"))
+	if !c.RequireMsgAuth || !c.Allowed(zdict.AccountingRequest) || c.Allowed(zdict.AccessRequest) {
+		t.Fatalf("bad policy: %+v", c)
+	}
+	if c = cs.Find(netip.MustParseAddr("10.1.0.1")); c.ShortName != "narrow" || c.NASType != "cisco" {
+		t.Fatalf("bad options: %+v", c)
+	}
+	// Add replaces client with the same prefix, Del removes it
+	if err := cs.Add(&NAS{Prefix: netip.MustParsePrefix("10.1.255.255/16"), Secret: []byte("new")}); err != nil {
+		t.Fatal(err)
+	}
+	if c = cs.Find(netip.MustParseAddr("10.1.0.1")); string(c.Secret) != "new" {
+		t.Fatalf("got %q", c.Secret)
+	}
+	cs.Del(netip.MustParsePrefix("10.1.0.0/16"))
+	if c = cs.Find(netip.MustParseAddr("10.1.0.1")); string(c.Secret) != "net8" {
+		t.Fatalf("got %q", c.Secret)
+	}
+	if err := cs.Add(&NAS{Prefix: netip.MustParsePrefix("10.2.0.0/16")}); err == nil {
+		t.Fatal("empty secret is added")
+	}
+}
+
+func TestClientsLoadErrors(t *testing.T) {
+	for _, text := range []string{
+		"10.0.0.1\n",
+		"10.0.0.300 secret\n",
+		"10.0.0.0/33 secret\n",
+		"10.0.0.1 secret bad\n",
+		"10.0.0.1 secret unknown=1\n",
+		"10.0.0.1 secret codes=1,256\n",
+		"10.0.0.1 secret require_message_authenticator=maybe\n",
+	} {
+		path := filepath.Join(t.TempDir(), "clients")
+		os.WriteFile(path, []byte(text), 0644)
+		if err := NewClients().LoadFile(path); err == nil {
+			t.Fatalf("%q: no error", text)
+		}
+	}
+	if err := NewClients().Reload(); err == nil {
+		t.Fatal("Reload without file: no error")
+	}
+}
+
+func TestClientsReload(t *testing.T) {
+	ip := netip.MustParseAddr("10.0.0.1")
+	cs, path := testClients(t, "10.0.0.0/8 old\n")
+	c := cs.Find(ip)
+	// same content is not loaded again even if file is touched
+	mt := time.Now().Add(time.Hour)
+	os.Chtimes(path, mt, mt)
+	if err := cs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cs.Find(ip) != c {
+		t.Fatal("unchanged file is reloaded")
+	}
+	// changed content is loaded even if modification time is the same
+	os.WriteFile(path, []byte("10.0.0.0/8 new\n"), 0644)
+	os.Chtimes(path, mt, mt)
+	if err := cs.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if string(cs.Find(ip).Secret) != "new" {
+		t.Fatal("changed file is not reloaded")
+	}
+	// registry is not changed on error
+	os.WriteFile(path, []byte("10.0.0.0/8\n"), 0644)
+	if err := cs.Reload(); err == nil {
+		t.Fatal("no error")
+	}
+	if string(cs.Find(ip).Secret) != "new" {
+		t.Fatal("registry is changed on error")
+	}
+}
+
+func TestClientsCheck(t *testing.T) {
+	cs, _ := testClients(t, `127.0.0.1 secret codes=1
+127.0.0.2 secret require_message_authenticator=true
+`)
+	check := func(ip string, req *Packet) error {
+		if err := req.Encode(true); err != nil {
+			t.Fatal(err)
+		}
+		pkt := &Packet{
+			data: req.data,
+			addr: net.UDPAddrFromAddrPort(netip.AddrPortFrom(netip.MustParseAddr(ip), 1812)),
+		}
+		if err := cs.Check(pkt); err != nil {
+			return err
+		}
+		if string(pkt.secret) != "secret" || pkt.GetClient() != cs.Find(netip.MustParseAddr(ip)) {
+			t.Fatalf("%s: secret or client is not set", ip)
+		}
+		return nil
+	}
+	req := func(code byte, ma bool) *Packet {
+		pkt := RadNew(code)
+		pkt.SetSecret([]byte("secret"))
+		if ma {
+			pkt.AddMsgAuth()
+		}
+		return pkt
+	}
+	if err := check("127.0.0.1", req(zdict.AccessRequest, false)); err != nil {
+		t.Fatal(err)
+	}
+	if err := check("127.0.0.1", req(zdict.AccountingRequest, false)); err != ErrCodeDenied {
+		t.Fatalf("got %v", err)
+	}
+	if err := check("127.0.0.3", req(zdict.AccessRequest, false)); err != ErrUnknownClient {
+		t.Fatalf("got %v", err)
+	}
+	if err := check("127.0.0.2", req(zdict.AccessRequest, false)); err == nil {
+		t.Fatal("no Message-Authenticator: no error")
+	}
+	if err := check("127.0.0.2", req(zdict.AccessRequest, true)); err != nil {
+		t.Fatal(err)
+	}
+	// only Access-Request requires Message-Authenticator
+	if err := check("127.0.0.2", req(zdict.AccountingRequest, false)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,154 @@
+package zradius
+
+import (
+	"container/list"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// DupCache - replies cache for duplicate request detection (RFC 5080 §2.2.2),
+// request is duplicate if it has the same source addr, port, ID and authenticator,
+// Status-Server is not cached (RFC 5997 §3)
+type DupCache struct {
+	mu     sync.Mutex
+	ttl    time.Duration            // entry lifetime
+	max    int                      // max entries, oldest are evicted first
+	m      map[dupKey]*list.Element // entries by key
+	fifo   list.List                // entries in order of last update, expire in the same order
+	hits   uint64                   // duplicates found
+	misses uint64                   // new requests
+}
+
+// duplicate cache key
+type dupKey struct {
+	addr netip.AddrPort
+	id   byte
+	auth [16]byte
+}
+
+// duplicate cache entry
+type dupEntry struct {
+	key   dupKey
+	exp   time.Time // expiration, ttl is started again when reply is stored
+	reply []byte    // encoded reply, nil if request is in progress or has no reply
+}
+
+// DupStats - duplicate cache metrics
+type DupStats struct {
+	Hits   uint64 // duplicates found
+	Misses uint64 // new requests
+	Size   int    // current entries
+}
+
+// NewDupCache - create duplicate cache with max entries and entry ttl
+func NewDupCache(max int, ttl time.Duration) *DupCache {
+	return &DupCache{
+		ttl: ttl,
+		max: max,
+		m:   make(map[dupKey]*list.Element),
+	}
+}
+
+// dupKeyOf - cache key for request
+func dupKeyOf(pkt *Packet) dupKey {
+	k := dupKey{id: pkt.id, auth: pkt.auth}
+	if len(pkt.data) >= MinPLen { // raw data, packet may be not decoded yet
+		k.id = pkt.data[1]
+		copy(k.auth[:], pkt.data[4:MinPLen])
+	}
+	if pkt.addr != nil {
+		k.addr = pkt.addr.AddrPort()
+		k.addr = netip.AddrPortFrom(k.addr.Addr().Unmap(), k.addr.Port())
+	}
+	return k
+}
+
+// expire - remove expired entries, lock must be held
+func (c *DupCache) expire(now time.Time) {
+	for e := c.fifo.Front(); e != nil; e = c.fifo.Front() {
+		if de := e.Value.(*dupEntry); now.Before(de.exp) && (c.max <= 0 || c.fifo.Len() <= c.max) {
+			return
+		}
+		c.remove(e)
+	}
+}
+
+// remove - remove entry, lock must be held
+func (c *DupCache) remove(e *list.Element) {
+	delete(c.m, e.Value.(*dupEntry).key)
+	c.fifo.Remove(e)
+}
+
+// dupSkip - request is not cached
+func dupSkip(pkt *Packet) bool {
+	code := pkt.code
+	if len(pkt.data) >= MinPLen { // raw data, packet may be not decoded yet
+		code = pkt.data[0]
+	}
+	return code == zdict.StatusServer
+}
+
+// Check - check if request is duplicate, for duplicate returns cached reply (nil if request is
+// in progress or has no reply) and true, new request is added to cache and false is returned
+func (c *DupCache) Check(pkt *Packet) ([]byte, bool) {
+	if dupSkip(pkt) {
+		return nil, false
+	}
+	k := dupKeyOf(pkt)
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expire(now)
+	if e, ok := c.m[k]; ok {
+		atomic.AddUint64(&c.hits, 1)
+		return e.Value.(*dupEntry).reply, true
+	}
+	atomic.AddUint64(&c.misses, 1)
+	c.m[k] = c.fifo.PushBack(&dupEntry{key: k, exp: now.Add(c.ttl)}) // dropped if reply is not stored in ttl
+	c.expire(now)                                                    // max entries
+	return nil, false
+}
+
+// Store - save reply for request added by Check and start entry ttl, nil reply means duplicates
+// are silently dropped
+func (c *DupCache) Store(req, reply *Packet) {
+	k := dupKeyOf(req)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.m[k]
+	if !ok {
+		return
+	}
+	de := e.Value.(*dupEntry)
+	if reply != nil {
+		de.reply = reply.data
+	}
+	de.exp = time.Now().Add(c.ttl)
+	c.fifo.MoveToBack(e)
+}
+
+// Forget - remove request from cache, so retransmission is processed again
+func (c *DupCache) Forget(req *Packet) {
+	k := dupKeyOf(req)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.m[k]; ok {
+		c.remove(e)
+	}
+}
+
+// Stats - get cache metrics
+func (c *DupCache) Stats() DupStats {
+	c.mu.Lock()
+	n := c.fifo.Len()
+	c.mu.Unlock()
+	return DupStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+		Size:   n,
+	}
+}
@@ -0,0 +1,103 @@
+package zradius
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// testDupReq - raw request as received from addr
+func testDupReq(code, id byte, addr string) *Packet {
+	data := testPacket()
+	data[0], data[1] = code, id
+	ua, _ := net.ResolveUDPAddr("udp", addr)
+	return &Packet{data: data, addr: ua}
+}
+
+func TestDupCache(t *testing.T) {
+	c := NewDupCache(10, time.Minute)
+	req := testDupReq(zdict.AccountingRequest, 1, "10.0.0.1:1000")
+	if _, dup := c.Check(req); dup {
+		t.Fatal("new request is duplicate")
+	}
+	if data, dup := c.Check(req); !dup || data != nil {
+		t.Fatal("request in progress is not duplicate")
+	}
+	rep := &Packet{data: []byte("reply")}
+	c.Store(req, rep)
+	if data, dup := c.Check(req); !dup || !bytes.Equal(data, rep.data) {
+		t.Fatalf("got %q", data)
+	}
+	// same request from other port or IPv4-mapped addr
+	if _, dup := c.Check(testDupReq(zdict.AccountingRequest, 1, "10.0.0.1:1001")); dup {
+		t.Fatal("other port is duplicate")
+	}
+	if _, dup := c.Check(testDupReq(zdict.AccountingRequest, 1, "[::ffff:10.0.0.1]:1000")); !dup {
+		t.Fatal("IPv4-mapped addr is not duplicate")
+	}
+	c.Forget(req)
+	if _, dup := c.Check(req); dup {
+		t.Fatal("forgotten request is duplicate")
+	}
+	if st := c.Stats(); st.Hits != 3 || st.Misses != 3 || st.Size != 2 {
+		t.Fatalf("got %+v", st)
+	}
+}
+
+func TestDupCacheTTL(t *testing.T) {
+	const ttl = 200 * time.Millisecond
+
+	c := NewDupCache(10, ttl)
+	slow := testDupReq(zdict.AccessRequest, 1, "10.0.0.1:1000")
+	fast := testDupReq(zdict.AccessRequest, 2, "10.0.0.1:1000")
+	c.Check(slow)
+	time.Sleep(ttl / 2)
+	c.Check(fast)
+	c.Store(fast, &Packet{data: []byte("fast")})
+	time.Sleep(ttl * 3 / 4)
+	// ttl is started by Store, so reply of slow handler is cached for full ttl
+	c.Store(slow, &Packet{data: []byte("slow")})
+	if data, dup := c.Check(slow); !dup || string(data) != "slow" {
+		t.Fatalf("got %q", data)
+	}
+	if data, dup := c.Check(fast); !dup || string(data) != "fast" {
+		t.Fatalf("got %q", data)
+	}
+	time.Sleep(ttl / 2)
+	if _, dup := c.Check(fast); dup {
+		t.Fatal("expired request is duplicate")
+	}
+	if _, dup := c.Check(slow); !dup {
+		t.Fatal("request is expired before ttl after Store")
+	}
+}
+
+func TestDupCacheMax(t *testing.T) {
+	c := NewDupCache(2, time.Minute)
+	for id := byte(1); id <= 3; id++ {
+		c.Check(testDupReq(zdict.AccessRequest, id, "10.0.0.1:1000"))
+	}
+	if st := c.Stats(); st.Size != 2 {
+		t.Fatalf("got %+v", st)
+	}
+	if _, dup := c.Check(testDupReq(zdict.AccessRequest, 1, "10.0.0.1:1000")); dup {
+		t.Fatal("oldest request is not evicted")
+	}
+}
+
+func TestDupCacheStatus(t *testing.T) {
+	c := NewDupCache(10, time.Minute)
+	req := testDupReq(zdict.StatusServer, 1, "10.0.0.1:1000")
+	for i := 0; i < 2; i++ {
+		if _, dup := c.Check(req); dup {
+			t.Fatal("Status-Server is duplicate")
+		}
+		c.Store(req, &Packet{data: []byte("reply")})
+	}
+	if st := c.Stats(); st.Size != 0 || st.Hits != 0 || st.Misses != 0 {
+		t.Fatalf("got %+v", st)
+	}
+}
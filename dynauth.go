@@ -0,0 +1,146 @@
+package zradius
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// DynAuthPort - Dynamic Authorization port (RFC 5176)
+const DynAuthPort = 3799
+
+// AttrVal - attr name and value for AddAttr
+type AttrVal struct {
+	Name string      // attr name
+	Val  interface{} // attr value, see AddAttr
+}
+
+// DynAuthResult - result of CoA or Disconnect request
+type DynAuthResult struct {
+	ACK   bool    // ACK is received, NAK otherwise
+	Cause uint32  // Error-Cause from reply, 0 if not present
+	Reply *Packet // decoded reply
+}
+
+// CauseName - Error-Cause name from dictionary, number if not found
+func (r *DynAuthResult) CauseName() string {
+	if r.Cause == 0 {
+		return ""
+	}
+	if n := r.Reply.GetAttr("Error-Cause").GetEnum(r.Reply); n != "" {
+		return n
+	}
+	return strconv.FormatUint(uint64(r.Cause), 10)
+}
+
+// DynAuth - Dynamic Authorization client (RFC 5176), RadClient is created for each NAS on first use
+type DynAuth struct {
+	Secret  SecretFunc         // NAS secret lookup, must be set
+	Dict    *zdict.Dictionary  // dictionary for requests and replies, zdict.Default if nil
+	Setup   func(c *RadClient) // called for new NAS client to set retransmission params, may be nil
+	mu      sync.Mutex
+	clients map[string]*RadClient // clients by NAS addr
+}
+
+// NewDynAuth - create Dynamic Authorization client with NAS secret lookup
+func NewDynAuth(secret SecretFunc) *DynAuth {
+	return &DynAuth{
+		Secret:  secret,
+		clients: make(map[string]*RadClient),
+	}
+}
+
+// Close - close all NAS clients
+func (da *DynAuth) Close() {
+	da.mu.Lock()
+	defer da.mu.Unlock()
+	for k, c := range da.clients {
+		c.Close()
+		delete(da.clients, k)
+	}
+}
+
+// hostPort - add port to addr ("host", "[ipv6]" or "host:port") if it has no port
+func hostPort(addr string, port int) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"), strconv.Itoa(port))
+}
+
+// client - find or create client for NAS, DynAuthPort is used if nas has no port
+func (da *DynAuth) client(nas string) (*RadClient, error) {
+	nas = hostPort(nas, DynAuthPort)
+	da.mu.Lock()
+	defer da.mu.Unlock()
+	if c, ok := da.clients[nas]; ok {
+		return c, nil
+	}
+	ua, err := net.ResolveUDPAddr("udp", nas)
+	if err != nil {
+		return nil, err
+	}
+	var secret []byte
+	if da.Secret != nil {
+		secret = da.Secret(ua)
+	}
+	if secret == nil {
+		return nil, ErrUnknownClient
+	}
+	c, err := NewRadClient(nas, secret)
+	if err != nil {
+		return nil, err
+	}
+	c.Dict = da.Dict
+	if da.Setup != nil {
+		da.Setup(c)
+	}
+	da.clients[nas] = c
+	return c, nil
+}
+
+// request - send CoA or Disconnect request and check reply
+func (da *DynAuth) request(ctx context.Context, code byte, nas string, attrs []AttrVal) (*DynAuthResult, error) {
+	c, err := da.client(nas)
+	if err != nil {
+		return nil, err
+	}
+	req := RadNew(code)
+	req.SetDict(da.Dict)
+	for _, a := range attrs {
+		if err = req.AddAttr(a.Name, a.Val); err != nil {
+			return nil, err
+		}
+	}
+	rep, err := c.Exchange(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	r := &DynAuthResult{Reply: rep}
+	switch rep.code {
+	case code + 1: // ACK
+		r.ACK = true
+	case code + 2: // NAK
+	default:
+		return nil, fmt.Errorf("Unexpected reply code %d for request code %d", rep.code, code)
+	}
+	if a := rep.GetAttr("Error-Cause"); a != nil {
+		r.Cause, _ = a.GetEData(rep).(uint32)
+	}
+	return r, nil
+}
+
+// Disconnect - send Disconnect-Request to NAS ("host[:port]") with session identification attrs
+func (da *DynAuth) Disconnect(ctx context.Context, nas string, attrs []AttrVal) (*DynAuthResult, error) {
+	return da.request(ctx, zdict.DisconnectRequest, nas, attrs)
+}
+
+// CoA - send CoA-Request to NAS ("host[:port]") with session identification and authorization attrs
+func (da *DynAuth) CoA(ctx context.Context, nas string, attrs []AttrVal) (*DynAuthResult, error) {
+	return da.request(ctx, zdict.CoARequest, nas, attrs)
+}
@@ -0,0 +1,162 @@
+package zradius
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// SessionKey - session identification attrs from CoA or Disconnect request, empty fields are not present
+type SessionKey struct {
+	AcctSessionID string // Acct-Session-Id
+	UserName      string // User-Name
+	FramedIP      net.IP // Framed-IP-Address
+}
+
+// SessionStore - NAS sessions storage for Dynamic Authorization server,
+// methods return number of matched sessions, error may be DynAuthError to set Error-Cause of NAK
+type SessionStore interface {
+	Disconnect(key SessionKey, req *Packet) (int, error) // terminate matched sessions
+	CoA(key SessionKey, req *Packet) (int, error)        // change authorization of matched sessions
+}
+
+// DynAuthError - error with Error-Cause value (RFC 5176 §3.5)
+type DynAuthError uint32
+
+// Error - error message with cause number
+func (e DynAuthError) Error() string {
+	return fmt.Sprintf("Dynamic Authorization error, Error-Cause: %d", uint32(e))
+}
+
+// DynAuthHandler - Dynamic Authorization server handler (RFC 5176) for NAS emulation,
+// it is registered for CoA-Request and Disconnect-Request by ListenDynAuth
+type DynAuthHandler struct {
+	Store  SessionStore // sessions storage, must be set
+	NASIP  net.IP       // NAS-IP-Address of emulated NAS, not checked if nil
+	NASIP6 net.IP       // NAS-IPv6-Address of emulated NAS, not checked if nil
+	NASID  string       // NAS-Identifier of emulated NAS, not checked if empty
+}
+
+// ListenDynAuth - listen on addr ("host" or "host:port", DynAuthPort if port is not set) and create Server
+// with h registered for CoA-Request and Disconnect-Request, Server.Serve must be called by caller
+func ListenDynAuth(addr string, secret SecretFunc, h *DynAuthHandler) (*Server, error) {
+	ua, err := net.ResolveUDPAddr("udp", hostPort(addr, DynAuthPort))
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", ua)
+	if err != nil {
+		return nil, err
+	}
+	s := NewServer(conn, secret)
+	s.Handle(zdict.CoARequest, h)
+	s.Handle(zdict.DisconnectRequest, h)
+	return s, nil
+}
+
+// sessionKey - get session identification attrs from request, false if none is present
+func sessionKey(req *Packet) (SessionKey, bool) {
+	var (
+		key SessionKey
+		ok  bool
+	)
+
+	if a := req.GetAttr("Acct-Session-Id"); a != nil {
+		key.AcctSessionID, ok = string(a.data), true
+	}
+	if a := req.GetAttr("User-Name"); a != nil {
+		key.UserName, ok = string(a.data), true
+	}
+	if a := req.GetAttr("Framed-IP-Address"); a != nil && len(a.data) == net.IPv4len {
+		key.FramedIP, ok = net.IPv4(a.data[0], a.data[1], a.data[2], a.data[3]), true
+	}
+	return key, ok
+}
+
+// checkIP - all attrs have ip of len l
+func checkIP(attrs []*Attr, ip net.IP, l int) bool {
+	for _, a := range attrs {
+		if len(a.data) != l || !net.IP(a.data).Equal(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkNAS - check NAS identification attrs in request
+func (h *DynAuthHandler) checkNAS(req *Packet) bool {
+	if h.NASIP != nil && !checkIP(req.GetAttrs("NAS-IP-Address"), h.NASIP, net.IPv4len) {
+		return false
+	}
+	if h.NASIP6 != nil && !checkIP(req.GetAttrs("NAS-IPv6-Address"), h.NASIP6, net.IPv6len) {
+		return false
+	}
+	if h.NASID != "" {
+		for _, a := range req.GetAttrs("NAS-Identifier") {
+			if string(a.data) != h.NASID {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// cause - Error-Cause for SessionStore result
+func cause(code byte, n int, err error) uint32 {
+	if e, ok := err.(DynAuthError); ok {
+		return uint32(e)
+	}
+	switch {
+	case err != nil && code == zdict.DisconnectRequest:
+		return zdict.CauseSessionNotRemovable
+	case err != nil:
+		return zdict.CauseNoResources
+	case n == 0:
+		return zdict.CauseSessionNotFound
+	}
+	return 0
+}
+
+// ServeRadius - process CoA-Request or Disconnect-Request and reply ACK or NAK with Error-Cause
+func (h *DynAuthHandler) ServeRadius(req *Packet) *Packet {
+	var (
+		n   int
+		err error
+		ec  uint32
+		ma  bool
+	)
+
+	if req.code != zdict.CoARequest && req.code != zdict.DisconnectRequest {
+		return nil
+	}
+	key, ok := sessionKey(req)
+	switch {
+	case !h.checkNAS(req):
+		ec = zdict.CauseNASIDMismatch
+	case !ok:
+		ec = zdict.CauseMissingAttr
+	case req.code == zdict.DisconnectRequest:
+		n, err = h.Store.Disconnect(key, req)
+		ec = cause(req.code, n, err)
+	default:
+		n, err = h.Store.CoA(key, req)
+		ec = cause(req.code, n, err)
+	}
+	code := req.code + 1 // ACK
+	if ec != 0 {
+		code++ // NAK
+	}
+	rep := req.RadReply(code)
+	for _, a := range req.attr {
+		ma = ma || a.typ == zdict.AttrMsgAuth
+	}
+	if ma {
+		rep.AddMsgAuth()
+	}
+	if ec != 0 {
+		rep.AddAttr("Error-Cause", ec) // NAK is sent without cause if dictionary has no Error-Cause
+	}
+	rep.attr = append(rep.attr, req.GetAttrs("Proxy-State")...) // copied to reply (RFC 2865 §5.33)
+	return rep
+}
@@ -0,0 +1,172 @@
+package zradius
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// testStore - SessionStore with fixed result, keys are saved
+type testStore struct {
+	mu   sync.Mutex
+	n    int
+	err  error
+	keys []SessionKey
+}
+
+func (s *testStore) result(key SessionKey) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append(s.keys, key)
+	return s.n, s.err
+}
+
+func (s *testStore) Disconnect(key SessionKey, _ *Packet) (int, error) {
+	return s.result(key)
+}
+
+func (s *testStore) CoA(key SessionKey, _ *Packet) (int, error) {
+	return s.result(key)
+}
+
+// testDynAuthReq - CoA or Disconnect request with attrs
+func testDynAuthReq(code byte, attrs ...AttrVal) *Packet {
+	req := RadNew(code)
+	for _, a := range attrs {
+		req.MustAddAttr(a.Name, a.Val)
+	}
+	return req
+}
+
+// errorCause - Error-Cause of reply, 0 if not present
+func errorCause(rep *Packet) uint32 {
+	if a := rep.GetAttr("Error-Cause"); a != nil {
+		return binary.BigEndian.Uint32(a.GetData())
+	}
+	return 0
+}
+
+func TestDynAuthHandler(t *testing.T) {
+	st := &testStore{n: 1}
+	h := &DynAuthHandler{
+		Store:  st,
+		NASIP:  net.IPv4(192, 0, 2, 1),
+		NASIP6: net.ParseIP("2001:db8::1"),
+		NASID:  "nas1",
+	}
+	user := AttrVal{"User-Name", "bob"}
+	for _, tc := range []struct {
+		name  string
+		code  byte
+		attrs []AttrVal
+		reply byte
+		cause uint32
+	}{
+		{"ACK", zdict.DisconnectRequest, []AttrVal{user}, zdict.DisconnectACK, 0},
+		{"NAS-IP-Address", zdict.CoARequest, []AttrVal{user, {"NAS-IP-Address", net.IPv4(192, 0, 2, 1)}}, zdict.CoAACK, 0},
+		{"NAS-IPv6-Address", zdict.CoARequest, []AttrVal{user, {"NAS-IPv6-Address", net.ParseIP("2001:db8::1")}}, zdict.CoAACK, 0},
+		{"NAS-Identifier", zdict.CoARequest, []AttrVal{user, {"NAS-Identifier", "nas1"}}, zdict.CoAACK, 0},
+		{"no session attrs", zdict.CoARequest, []AttrVal{{"NAS-Identifier", "nas1"}}, zdict.CoANAK, zdict.CauseMissingAttr},
+		{"NAS-IP-Address mismatch", zdict.CoARequest, []AttrVal{user, {"NAS-IP-Address", net.IPv4(192, 0, 2, 2)}}, zdict.CoANAK, zdict.CauseNASIDMismatch},
+		{"NAS-IPv6-Address mismatch", zdict.DisconnectRequest, []AttrVal{user, {"NAS-IPv6-Address", net.ParseIP("2001:db8::2")}}, zdict.DisconnectNAK, zdict.CauseNASIDMismatch},
+		{"NAS-Identifier mismatch", zdict.CoARequest, []AttrVal{user, {"NAS-Identifier", "nas2"}}, zdict.CoANAK, zdict.CauseNASIDMismatch},
+	} {
+		rep := h.ServeRadius(testDynAuthReq(tc.code, tc.attrs...))
+		if rep.GetCode() != tc.reply || errorCause(rep) != tc.cause {
+			t.Fatalf("%s: got %s", tc.name, rep)
+		}
+	}
+	// IPv6 NAS without NASIP6 is identified by NAS-Identifier
+	h.NASIP6 = nil
+	rep := h.ServeRadius(testDynAuthReq(zdict.CoARequest, user,
+		AttrVal{"NAS-IPv6-Address", net.ParseIP("2001:db8::2")}, AttrVal{"NAS-Identifier", "nas1"}))
+	if rep.GetCode() != zdict.CoAACK {
+		t.Fatalf("got %s", rep)
+	}
+	if h.ServeRadius(testDynAuthReq(zdict.AccessRequest, user)) != nil {
+		t.Fatal("reply for Access-Request")
+	}
+}
+
+func TestDynAuthHandlerStore(t *testing.T) {
+	st := &testStore{}
+	h := &DynAuthHandler{Store: st}
+	req := testDynAuthReq(zdict.DisconnectRequest,
+		AttrVal{"Acct-Session-Id", "s1"},
+		AttrVal{"User-Name", "bob"},
+		AttrVal{"Framed-IP-Address", net.IPv4(10, 0, 0, 1)},
+		AttrVal{"Proxy-State", []byte("p1")},
+		AttrVal{"Proxy-State", []byte("p2")},
+	)
+	req.AddMsgAuth()
+	for _, tc := range []struct {
+		code  byte
+		n     int
+		err   error
+		cause uint32
+	}{
+		{zdict.DisconnectRequest, 0, nil, zdict.CauseSessionNotFound},
+		{zdict.DisconnectRequest, 1, errors.New("busy"), zdict.CauseSessionNotRemovable},
+		{zdict.CoARequest, 1, errors.New("busy"), zdict.CauseNoResources},
+		{zdict.CoARequest, 1, DynAuthError(zdict.CauseUnsupportedService), zdict.CauseUnsupportedService},
+		{zdict.CoARequest, 2, nil, 0},
+	} {
+		st.n, st.err = tc.n, tc.err
+		req.code = tc.code
+		rep := h.ServeRadius(req)
+		want := tc.code + 1
+		if tc.cause != 0 {
+			want++
+		}
+		if rep.GetCode() != want || errorCause(rep) != tc.cause {
+			t.Fatalf("code %d, %d sessions, error %v: got %s", tc.code, tc.n, tc.err, rep)
+		}
+		ps := rep.GetAttrs("Proxy-State")
+		if len(ps) != 2 || string(ps[0].GetData()) != "p1" || string(ps[1].GetData()) != "p2" {
+			t.Fatal("Proxy-State is not copied")
+		}
+		if rep.GetAttr("Message-Authenticator") == nil {
+			t.Fatal("no Message-Authenticator in reply")
+		}
+	}
+	want := SessionKey{AcctSessionID: "s1", UserName: "bob", FramedIP: net.IPv4(10, 0, 0, 1)}
+	if k := st.keys[0]; k.AcctSessionID != want.AcctSessionID || k.UserName != want.UserName || !k.FramedIP.Equal(want.FramedIP) {
+		t.Fatalf("got %+v", k)
+	}
+}
+
+func TestListenDynAuth(t *testing.T) {
+	st := &testStore{n: 1}
+	s, err := ListenDynAuth("127.0.0.1:0", func(*net.UDPAddr) []byte { return testSecret },
+		&DynAuthHandler{Store: st, NASIP6: net.ParseIP("2001:db8::1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testServe(t, s)
+	da := testDynAuth(t)
+	nas := s.conn.LocalAddr().String()
+	r, err := da.CoA(context.Background(), nas, []AttrVal{
+		{"User-Name", "bob"},
+		{"NAS-IPv6-Address", net.ParseIP("2001:db8::1")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.ACK {
+		t.Fatalf("NAK for IPv6 identified NAS: %s", r.CauseName())
+	}
+	if r, err = da.Disconnect(context.Background(), nas, []AttrVal{
+		{"User-Name", "bob"},
+		{"NAS-IPv6-Address", net.ParseIP("2001:db8::2")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if r.ACK || r.Cause != zdict.CauseNASIDMismatch || r.CauseName() != "NAS-Identification-Mismatch" {
+		t.Fatalf("got %+v", r)
+	}
+}
@@ -0,0 +1,117 @@
+package zradius
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// testNAS - NAS emulator: ACK for User-Name "bob", NAK with Session-Context-Not-Found for others,
+// Access-Accept for User-Name "bad"
+func testNAS(t *testing.T) *net.UDPConn {
+	t.Helper()
+	return testUDPServer(t, func(req *Packet) *Packet {
+		if err := req.Verify(testSecret); err != nil {
+			t.Errorf("NAS: %v", err)
+			return nil
+		}
+		switch string(req.GetAttr("User-Name").GetData()) {
+		case "bob":
+			return req.RadReply(req.GetCode() + 1)
+		case "bad":
+			return req.RadReply(zdict.AccessAccept)
+		}
+		rep := req.RadReply(req.GetCode() + 2)
+		rep.MustAddAttr("Error-Cause", zdict.CauseSessionNotFound)
+		return rep
+	})
+}
+
+// testDynAuth - DynAuth client with testSecret for local NAS
+func testDynAuth(t *testing.T) *DynAuth {
+	t.Helper()
+	da := NewDynAuth(func(addr *net.UDPAddr) []byte {
+		if addr.IP.IsLoopback() {
+			return testSecret
+		}
+		return nil
+	})
+	da.Setup = func(c *RadClient) {
+		c.IRT = 50 * time.Millisecond
+		c.MRC = -1
+		c.MRD = time.Second
+	}
+	t.Cleanup(da.Close)
+	return da
+}
+
+func TestDynAuth(t *testing.T) {
+	nas := testNAS(t).LocalAddr().String()
+	da := testDynAuth(t)
+	user := func(name string) []AttrVal {
+		return []AttrVal{{"User-Name", name}, {"Acct-Session-Id", "s1"}}
+	}
+	r, err := da.Disconnect(context.Background(), nas, user("bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.ACK || r.Cause != 0 || r.CauseName() != "" || r.Reply.GetCode() != zdict.DisconnectACK {
+		t.Fatalf("got %+v", r)
+	}
+	if r, err = da.CoA(context.Background(), nas, user("alice")); err != nil {
+		t.Fatal(err)
+	}
+	if r.ACK || r.Cause != zdict.CauseSessionNotFound || r.CauseName() != "Session-Context-Not-Found" ||
+		r.Reply.GetCode() != zdict.CoANAK {
+		t.Fatalf("got %+v", r)
+	}
+	if len(da.clients) != 1 {
+		t.Fatalf("%d clients for one NAS", len(da.clients))
+	}
+	if _, err = da.CoA(context.Background(), nas, user("bad")); err == nil {
+		t.Fatal("unexpected reply code: no error")
+	}
+	if _, err = da.CoA(context.Background(), nas, []AttrVal{{"No-Such-Attr", 1}}); err == nil {
+		t.Fatal("unknown attr: no error")
+	}
+}
+
+func TestDynAuthClient(t *testing.T) {
+	da := testDynAuth(t)
+	if _, err := da.Disconnect(context.Background(), "192.0.2.1", nil); err != ErrUnknownClient {
+		t.Fatalf("got %v", err)
+	}
+	c, err := da.client("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GetAddr().Port != DynAuthPort || c.IRT != 50*time.Millisecond {
+		t.Fatalf("got %s, IRT %v", c.GetAddr(), c.IRT)
+	}
+	// IPv6 NAS without port
+	if c, err = da.client("[::1]"); err != nil {
+		t.Fatal(err)
+	}
+	if ua := c.GetAddr(); ua.Port != DynAuthPort || !ua.IP.Equal(net.IPv6loopback) {
+		t.Fatalf("got %s", ua)
+	}
+	for addr, want := range map[string]string{
+		"192.0.2.1":          "192.0.2.1:3799",
+		"192.0.2.1:1700":     "192.0.2.1:1700",
+		"nas.example":        "nas.example:3799",
+		"2001:db8::1":        "[2001:db8::1]:3799",
+		"[2001:db8::1]":      "[2001:db8::1]:3799",
+		"[2001:db8::1]:1700": "[2001:db8::1]:1700",
+	} {
+		if got := hostPort(addr, DynAuthPort); got != want {
+			t.Fatalf("%s: got %s, want %s", addr, got, want)
+		}
+	}
+	da.Close()
+	if len(da.clients) != 0 {
+		t.Fatal("clients are not closed")
+	}
+}
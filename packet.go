@@ -9,27 +9,33 @@ import (
 	"net"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/andrewz1/zradius/zdict"
 )
 
 // Packet - Radius packet storage
 type Packet struct {
-	conn   *net.UDPConn // соединение через которое этот пакет был получен
-	addr   *net.UDPAddr // откуда этот пакет был получен или куда должен быть отправлен ответ
-	code   byte         // radius code, Request, Accept, Reject and etc.
-	id     byte         // radius id
-	len    uint16       // длина из пакета
-	auth   [16]byte     // авторизационные данные из пакета
-	attr   []*Attr      // слайс с аттрибутами
-	secret []byte       // секрет для этого пакета
-	data   []byte       // raw packet data
-	ctx    interface{}  // user context
+	conn   *net.UDPConn      // соединение через которое этот пакет был получен
+	addr   *net.UDPAddr      // откуда этот пакет был получен или куда должен быть отправлен ответ
+	code   byte              // radius code, Request, Accept, Reject and etc.
+	id     byte              // radius id
+	len    uint16            // длина из пакета
+	rlen   int               // сколько байт было получено, может быть больше len
+	auth   [16]byte          // авторизационные данные из пакета
+	attr   []*Attr           // слайс с аттрибутами
+	secret []byte            // секрет для этого пакета
+	data   []byte            // raw packet data
+	ctx    interface{}       // user context
+	req    *Packet           // запрос, на который этот пакет является ответом
+	dict   *zdict.Dictionary // словарь для этого пакета, zdict.Default если nil
+	client *NAS              // клиент из реестра, от которого получен пакет
 }
 
 var (
-	pbPool sync.Pool
-	radID  uint32
+	pbPool   sync.Pool
+	radID    uint32
+	zeroAuth [16]byte
 )
 
 func getPBuf() []byte {
@@ -77,58 +83,135 @@ func RadRecv(conn *net.UDPConn) (pkt *Packet, err error) {
 		conn: conn,
 		addr: addr,
 		len:  pl,
+		rlen: num,
 		data: append([]byte(nil), buf[:pl]...),
 	}
 	return pkt, nil
 }
 
-// parse VSA attr
-func (pkt *Packet) parseVSA(data []byte) error {
+// parse VSA attr using vendor format, frag - WiMAX attr waiting for next fragment
+func (pkt *Packet) parseVSA(data []byte, frag *Attr) (*Attr, error) {
 	var (
-		bp, bl int    // buffer pointer and left
-		val    int    // vendor attr data len
-		vid    uint32 // vendor ID
-		vt, vl byte   // vendor type and len
+		bp, bl int               // buffer pointer and left
+		val    int               // vendor attr data len
+		vid    uint32            // vendor ID
+		vt     uint32            // vendor type
+		vl     int               // vendor len
+		vend   *zdict.VendorData // vendor from dictionary
+		more   bool              // WiMAX continuation flag
 	)
 
 	bl = len(data)
-	if bl < 6 {
-		return fmt.Errorf("VSA len minimal is 6, len = %d", bl)
+	if bl < 4 {
+		return nil, fmt.Errorf("VSA len minimal is 6, len = %d", bl+2)
 	}
 	vid = binary.BigEndian.Uint32(data[bp:])
 	bp += 4
 	bl -= 4
-	for bl >= 2 {
-		vt = data[bp]
-		vl = data[bp+1]
-		bp += 2
-		bl -= 2
-		val = int(vl) - 2
-		if val > bl {
-			return fmt.Errorf("Vendor attr len error, buffer len = %d, attr len = %d", bl, val)
+	vend = pkt.GetDict().FindVendorID(vid)
+	tl, ll, cont := vsaFormat(vend)
+	hlen := vsaHdrLen(vend)
+	if bl < hlen {
+		return nil, fmt.Errorf("Vendor %d attr len error, buffer len = %d, header len = %d", vid, bl, hlen)
+	}
+	for bl >= hlen {
+		vt = getUintN(data[bp:], tl)
+		vl = bl // no VendorLen - attr is up to the end of VSA
+		if ll > 0 {
+			vl = int(getUintN(data[bp+tl:], ll))
+		}
+		if cont {
+			more = data[bp+tl+ll]&vsaFlagMore != 0
 		}
-		attr := &Attr{
-			typ:  zdict.AttrVSA,
-			len:  vl + 6,
-			vid:  vid,
-			vtyp: vt,
-			vlen: vl,
-			data: data[bp : bp+val],
-			atyp: zdict.FindVSABin(vid, vt),
+		val = vl - hlen
+		if val < 0 || val > bl-hlen {
+			return nil, fmt.Errorf("Vendor attr len error, buffer len = %d, attr len = %d", bl-hlen, val)
+		}
+		bp += hlen
+		bl -= hlen
+		if frag != nil && (vid != frag.vid || vt != frag.vtyp) {
+			return nil, fmt.Errorf("Attr %s is not continued", frag.name())
+		}
+		switch {
+		case frag != nil: // data is a copy already
+			frag.data = append(frag.data, data[bp:bp+val]...)
+		case more: // copy for appending next fragments
+			frag = &Attr{typ: zdict.AttrVSA, vid: vid, vtyp: vt, vend: vend, data: append([]byte(nil), data[bp:bp+val]...)}
+		default:
+			frag = &Attr{typ: zdict.AttrVSA, vid: vid, vtyp: vt, vend: vend, data: data[bp : bp+val]}
 		}
-		pkt.attr = append(pkt.attr, attr)
 		bp += val
 		bl -= val
+		if more {
+			continue
+		}
+		frag.atyp = pkt.GetDict().FindVSABin32(vid, vt)
+		frag.updateLen()
+		frag.splitTag()
+		pkt.attr = append(pkt.attr, frag)
+		frag = nil
 	}
-	return nil
+	if bl > 0 {
+		return nil, fmt.Errorf("Vendor %d attr len error, %d bytes left in VSA", vid, bl)
+	}
+	return frag, nil
+}
+
+// parse extended attr (RFC 6929), frag - Long-Extended attr waiting for next fragment
+func (pkt *Packet) parseExt(at byte, data []byte, frag *Attr) (*Attr, error) {
+	var (
+		hlen = 1 // Extended-Type
+		more bool
+		attr *Attr
+	)
+
+	if zdict.IsLongExt(at) {
+		hlen++ // flags
+	}
+	if len(data) < hlen {
+		return nil, fmt.Errorf("Extended attr len error, type: %d, data len: %d", at, len(data))
+	}
+	if frag != nil && data[0] != frag.ext {
+		return nil, fmt.Errorf("Attr %s is not continued", frag.name())
+	}
+	if hlen == 2 {
+		more = data[1]&extFlagMore != 0
+	}
+	switch {
+	case frag != nil: // data is a copy already
+		frag.data = append(frag.data, data[hlen:]...)
+	case more: // copy for appending next fragments
+		frag = &Attr{typ: at, ext: data[0], data: append([]byte(nil), data[hlen:]...)}
+	default:
+		frag = &Attr{typ: at, ext: data[0], data: data[hlen:]}
+	}
+	if more {
+		return frag, nil
+	}
+	attr = frag
+	if attr.ext == zdict.ExtEVS {
+		if len(attr.data) < 5 {
+			return nil, fmt.Errorf("EVS len minimal is 5, len = %d", len(attr.data))
+		}
+		attr.vid = binary.BigEndian.Uint32(attr.data)
+		attr.vtyp = uint32(attr.data[4])
+		attr.data = attr.data[5:]
+	}
+	attr.atyp = pkt.GetDict().FindAllExtBin32(attr.typ, attr.ext, attr.vid, attr.vtyp)
+	attr.updateLen()
+	attr.splitTag()
+	pkt.attr = append(pkt.attr, attr)
+	return nil, nil
 }
 
 // Decode - decode Radius packet from pkt.data
 func (pkt *Packet) Decode() (err error) {
 	var (
-		bp, bl int  // buffer pointer and buffer left
-		alen   int  // attr data len
-		at, al byte // attr type and len (raw)
+		bp, bl int   // buffer pointer and buffer left
+		alen   int   // attr data len
+		at, al byte  // attr type and len (raw)
+		frag   *Attr // not finished Long-Extended or WiMAX attr
+		cat    *Attr // last concat attr, consecutive parts are joined to it
 	)
 
 	bl = len(pkt.data)
@@ -148,92 +231,157 @@ func (pkt *Packet) Decode() (err error) {
 		if alen < 0 || alen > bl {
 			return fmt.Errorf("Attr len error, attr data len: %d, bytes left in buffer: %d", alen, bl)
 		}
-		if at == zdict.AttrVSA { // VSA
-			if err = pkt.parseVSA(pkt.data[bp : bp+alen]); err != nil {
+		if frag != nil && at != frag.typ {
+			return fmt.Errorf("Attr %s is not continued", frag.name())
+		}
+		switch {
+		case at == zdict.AttrVSA: // VSA
+			if frag, err = pkt.parseVSA(pkt.data[bp:bp+alen], frag); err != nil {
 				return err
 			}
-		} else { // Plain attr
+			cat = nil
+		case zdict.IsExt(at): // Extended attr
+			if frag, err = pkt.parseExt(at, pkt.data[bp:bp+alen], frag); err != nil {
+				return err
+			}
+			cat = nil
+		case cat != nil && cat.typ == at: // next part of concat attr, copy data on append
+			cat.data = append(cat.data[:len(cat.data):len(cat.data)], pkt.data[bp:bp+alen]...)
+			cat.updateLen()
+		default: // Plain attr
 			attr := &Attr{
 				typ:  at,
 				len:  al,
 				data: pkt.data[bp : bp+alen],
-				atyp: zdict.FindAttrBin(at),
+				atyp: pkt.GetDict().FindAttrBin(at),
 			}
+			attr.splitTag()
 			pkt.attr = append(pkt.attr, attr)
+			cat = nil
+			if attr.atyp != nil && attr.atyp.Concat { // only plain RFC attrs are joined
+				cat = attr
+			}
 		}
 		bp += alen
 		bl -= alen
 	}
+	if frag != nil {
+		return fmt.Errorf("Attr %s last fragment has More flag", frag.name())
+	}
 	return nil
 }
 
+// isHashAuth - request authenticator is MD5 over packet and secret (RFC 2866, RFC 5176)
+func isHashAuth(code byte) bool {
+	switch code {
+	case zdict.AccountingRequest, zdict.CoARequest, zdict.DisconnectRequest:
+		return true
+	}
+	return false
+}
+
 // Encode - encode Radius packet to pkt.data
 func (pkt *Packet) Encode(newPkt bool) (err error) {
 	var (
 		buf    []byte
 		bp, bl int
 		alen   int
+		hlen   int
 		a      *Attr
+		val    []byte
 		hmd    hash.Hash
+		mapos  int // Message-Authenticator value position
 	)
 
+	hashAuth := isHashAuth(pkt.code)
 	buf = getPBuf()
 	defer putPBuf(buf)
 	bl = len(buf)
 	buf[0] = pkt.code
 	buf[1] = pkt.id
-	if newPkt {
-		alen, err = rand.Read(buf[4:20]) // reuse alen
+	switch {
+	case newPkt && hashAuth:
+		copy(buf[4:MinPLen], zeroAuth[:]) // 16 zero octets, hash is calculated later
+	case newPkt:
+		alen, err = rand.Read(buf[4:MinPLen]) // reuse alen
 		if err != nil {
 			return err
 		}
 		if alen != 16 {
 			return fmt.Errorf("Random read error, request 16 bytes, got %d", alen)
 		}
-	} else {
+		copy(pkt.auth[:], buf[4:MinPLen]) // needed for User-Password and reply check
+	default:
 		copy(buf[4:], pkt.auth[:]) // this is old packet data
 	}
 	bp += MinPLen
 	bl -= MinPLen
 	for _, a = range pkt.attr {
-		if bl < 2 {
-			return fmt.Errorf("No space in buffer: used = %d, left = %d", bp, bl)
+		// request authenticator is already in buf
+		if val, err = a.wireData(pkt, buf[4:MinPLen]); err != nil {
+			return err
 		}
-		buf[bp] = a.typ
-		buf[bp+1] = a.len
-		bp += 2
-		bl -= 2
-		alen = int(a.len) - 2
-		if a.typ == zdict.AttrVSA {
-			if bl < 6 {
-				return fmt.Errorf("No space in buffer: used = %d, left = %d", bp, bl)
+		if zdict.IsExt(a.typ) || a.typ == zdict.AttrVSA {
+			if zdict.IsExt(a.typ) {
+				alen, err = a.encodeExt(buf[bp:], val)
+			} else {
+				alen, err = a.encodeVSA(buf[bp:], val)
 			}
-			binary.BigEndian.PutUint32(buf[bp:], a.vid)
-			bp += 4
-			bl -= 4
-			buf[bp] = a.vtyp
-			buf[bp+1] = a.vlen
-			bp += 2
-			bl -= 2
-			alen = int(a.vlen) - 2
-		}
-		if alen > 0 {
-			if bl < alen {
-				return fmt.Errorf("No space in buffer: used = %d, left = %d", bp, bl)
+			if err != nil {
+				return err
 			}
-			copy(buf[bp:], a.data)
 			bp += alen
 			bl -= alen
+			continue
+		}
+		alen = len(val)
+		hlen = 2
+		if a.typ == zdict.AttrMsgAuth { // calculated after all attrs
+			val = zeroAuth[:]
+			alen = len(val)
+			mapos = bp + hlen
+		}
+		if hlen+alen > 255 {
+			if a.atyp == nil || !a.atyp.Concat {
+				return fmt.Errorf("Attr %s too long, len: %d", a.name(), alen)
+			}
+			for ; alen > 255-hlen; alen = len(val) { // split concat attr
+				if bl < 255 {
+					return fmt.Errorf("No space in buffer: used = %d, left = %d", bp, bl)
+				}
+				buf[bp] = a.typ
+				buf[bp+1] = 255
+				copy(buf[bp+hlen:], val[:255-hlen])
+				val = val[255-hlen:]
+				bp += 255
+				bl -= 255
+			}
 		}
+		if bl < hlen+alen {
+			return fmt.Errorf("No space in buffer: used = %d, left = %d", bp, bl)
+		}
+		buf[bp] = a.typ
+		buf[bp+1] = byte(hlen + alen)
+		bp += hlen
+		bl -= hlen
+		copy(buf[bp:], val)
+		bp += alen
+		bl -= alen
 	}
 	pkt.len = uint16(bp)
 	binary.BigEndian.PutUint16(buf[2:], pkt.len)
-	if !newPkt {
+	if mapos > 0 { // authenticator field holds right value for HMAC here
+		copy(buf[mapos:], calcMsgAuth(buf[:bp], pkt.secret))
+	}
+	if !newPkt || hashAuth {
 		hmd = md5.New()
 		hmd.Write(buf[:bp])
 		hmd.Write(pkt.secret)
 		copy(buf[4:], hmd.Sum(nil))
 	}
+	if newPkt && hashAuth {
+		copy(pkt.auth[:], buf[4:MinPLen])
+	}
 	pkt.data = append([]byte(nil), buf[:bp]...)
 	return nil
 }
@@ -247,7 +395,40 @@ func (pkt *Packet) RadReply(code byte) *Packet {
 		id:     pkt.id,
 		auth:   pkt.auth,
 		secret: pkt.secret,
+		req:    pkt,
+		dict:   pkt.dict,
+	}
+}
+
+// SetRequest - set request Packet for reply, used for decrypting reply attrs
+func (pkt *Packet) SetRequest(req *Packet) {
+	pkt.req = req
+}
+
+// GetRequest - get request Packet for reply
+func (pkt *Packet) GetRequest() *Packet {
+	return pkt.req
+}
+
+// request authenticator for attr encryption
+func (pkt *Packet) reqAuth() []byte {
+	if pkt.req != nil {
+		return pkt.req.auth[:]
+	}
+	return pkt.auth[:]
+}
+
+// SetDict - set dictionary for packet, zdict.Default is used if not set
+func (pkt *Packet) SetDict(d *zdict.Dictionary) {
+	pkt.dict = d
+}
+
+// GetDict - get dictionary for packet
+func (pkt *Packet) GetDict() *zdict.Dictionary {
+	if pkt.dict == nil {
+		return zdict.Default
 	}
+	return pkt.dict
 }
 
 // SetSecret - set Radius shared secret for packet
@@ -260,6 +441,11 @@ func (pkt *Packet) SetSecretStr(s string) {
 	pkt.secret = []byte(s)
 }
 
+// GetClient - get NAS found by Clients.Check, nil if not checked
+func (pkt *Packet) GetClient() *NAS {
+	return pkt.client
+}
+
 // SetAddr - set Addr in Packet
 func (pkt *Packet) SetAddr(addr *net.UDPAddr) {
 	pkt.addr = addr
@@ -292,38 +478,168 @@ func (pkt *Packet) GetAttr(name string) *Attr {
 		attr *Attr
 	)
 
-	if ad = zdict.FindAttrName(name); ad == nil {
+	if ad = pkt.GetDict().FindAttrName(name); ad == nil {
 		return nil
 	}
 	for _, attr = range pkt.attr {
 		if attr.atyp == ad {
-			if attr.atyp.Enc == zdict.EncUsr {
-				attr.decryptUsr(pkt)
-			}
+			attr.decrypt(pkt)
 			return attr
 		}
 	}
 	return nil
 }
 
-// AddAttrRaw - add raw Attr to packet
-func (pkt *Packet) AddAttrRaw(name string, val []byte) error {
+// GetAttrs - search all attributes with given name, in packet order
+func (pkt *Packet) GetAttrs(name string) []*Attr {
+	var (
+		ad    *zdict.AttrData
+		attr  *Attr
+		attrs []*Attr
+	)
+
+	if ad = pkt.GetDict().FindAttrName(name); ad == nil {
+		return nil
+	}
+	for _, attr = range pkt.attr {
+		if attr.atyp == ad {
+			attr.decrypt(pkt)
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs
+}
+
+// RangeAttrs - call f for every attribute in packet order until f returns false
+func (pkt *Packet) RangeAttrs(f func(attr *Attr) bool) {
+	for _, attr := range pkt.attr {
+		attr.decrypt(pkt)
+		if !f(attr) {
+			return
+		}
+	}
+}
+
+// Count - return number of attributes with given name
+func (pkt *Packet) Count(name string) int {
+	var (
+		ad  *zdict.AttrData
+		num int
+	)
+
+	if ad = pkt.GetDict().FindAttrName(name); ad == nil {
+		return 0
+	}
+	for _, attr := range pkt.attr {
+		if attr.atyp == ad {
+			num++
+		}
+	}
+	return num
+}
+
+// delAttrs - delete attrs with dictionary entry ad from slice in place
+func delAttrs(attrs []*Attr, ad *zdict.AttrData) []*Attr {
+	res := attrs[:0]
+	for _, attr := range attrs {
+		if attr.atyp != ad {
+			res = append(res, attr)
+		}
+	}
+	for i := len(res); i < len(attrs); i++ {
+		attrs[i] = nil // release deleted attrs
+	}
+	return res
+}
+
+// DelAttr - delete all attributes with given name, return number of deleted attributes
+func (pkt *Packet) DelAttr(name string) int {
+	var ad *zdict.AttrData
+
+	if ad = pkt.GetDict().FindAttrName(name); ad == nil {
+		return 0
+	}
+	num := len(pkt.attr)
+	pkt.attr = delAttrs(pkt.attr, ad)
+	return num - len(pkt.attr)
+}
+
+// ReplaceAttr - replace all attributes with given name by one with new value,
+// new attribute takes place of the first replaced one or is added to the end
+func (pkt *Packet) ReplaceAttr(name string, val interface{}) error {
+	attr, err := pkt.newAttrVal(name, val)
+	if err != nil {
+		return err
+	}
+	for i, a := range pkt.attr {
+		if a.atyp == attr.atyp {
+			pkt.attr[i] = attr
+			pkt.attr = pkt.attr[:i+1+len(delAttrs(pkt.attr[i+1:], attr.atyp))]
+			return nil
+		}
+	}
+	pkt.attr = append(pkt.attr, attr)
+	return nil
+}
+
+// MustReplaceAttr - replace all attributes with given name by one with new value
+func (pkt *Packet) MustReplaceAttr(name string, val interface{}) {
+	if err := pkt.ReplaceAttr(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddMsgAuth - add Message-Authenticator to packet (as first attr), value is calculated by Encode
+func (pkt *Packet) AddMsgAuth() {
+	var attr *Attr
+
+	for _, attr = range pkt.attr {
+		if attr.typ == zdict.AttrMsgAuth {
+			return
+		}
+	}
+	attr = &Attr{
+		typ:  zdict.AttrMsgAuth,
+		data: zeroAuth[:],
+		atyp: pkt.GetDict().FindAttrBin(zdict.AttrMsgAuth),
+	}
+	attr.updateLen()
+	pkt.attr = append([]*Attr{attr}, pkt.attr...)
+}
+
+// newAttr - create Attr by name
+func (pkt *Packet) newAttr(name string, val []byte) (*Attr, error) {
 	var (
 		ad   *zdict.AttrData
 		attr *Attr
 	)
 
-	if ad = zdict.FindAttrName(name); ad == nil {
-		return fmt.Errorf("Attribute %s not found", name)
+	if ad = pkt.GetDict().FindAttrName(name); ad == nil {
+		return nil, fmt.Errorf("Attribute %s not found", name)
 	}
 	attr = &Attr{
 		typ:  ad.Typ,
+		ext:  ad.Ext,
 		vid:  ad.Vid,
-		vtyp: ad.Vtyp,
-		data: val,
+		vtyp: ad.Vtyp32,
 		atyp: ad,
+		dcr:  true, // data in clear text
+	}
+	if ad.Typ == zdict.AttrVSA {
+		attr.vend = pkt.GetDict().FindVendorID(ad.Vid)
+	}
+	if err := attr.setData(val); err != nil {
+		return nil, err
+	}
+	return attr, nil
+}
+
+// AddAttrRaw - add raw Attr to packet
+func (pkt *Packet) AddAttrRaw(name string, val []byte) error {
+	attr, err := pkt.newAttr(name, val)
+	if err != nil {
+		return err
 	}
-	attr.updateLen()
 	pkt.attr = append(pkt.attr, attr)
 	return nil
 }
@@ -377,3 +693,325 @@ func (pkt *Packet) MustAddAttrIP4(name string, val net.IP) {
 		panic(err)
 	}
 }
+
+// AddAttrRawTagged - add raw tagged Attr to packet
+func (pkt *Packet) AddAttrRawTagged(name string, tag byte, val []byte) error {
+	attr, err := pkt.newAttr(name, val)
+	if err != nil {
+		return err
+	}
+	if !attr.atyp.Tag {
+		return fmt.Errorf("Attribute %s is not tagged", name)
+	}
+	if tag > tagMax {
+		return fmt.Errorf("Tag value out of range: %d", tag)
+	}
+	attr.tag = tag
+	attr.tagd = true
+	attr.updateLen()
+	pkt.attr = append(pkt.attr, attr)
+	return nil
+}
+
+// MustAddAttrRawTagged - add raw tagged Attr to packet
+func (pkt *Packet) MustAddAttrRawTagged(name string, tag byte, val []byte) {
+	if err := pkt.AddAttrRawTagged(name, tag, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddAttrStrTagged - add string tagged Attr to packet
+func (pkt *Packet) AddAttrStrTagged(name string, tag byte, val string) error {
+	return pkt.AddAttrRawTagged(name, tag, []byte(val))
+}
+
+// MustAddAttrStrTagged - add string tagged Attr to packet
+func (pkt *Packet) MustAddAttrStrTagged(name string, tag byte, val string) {
+	if err := pkt.AddAttrStrTagged(name, tag, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddAttrIntTagged - add int tagged Attr to packet, value is 24 bit
+func (pkt *Packet) AddAttrIntTagged(name string, tag byte, val uint32) error {
+	var t [4]byte
+
+	if val > 0xFFFFFF {
+		return fmt.Errorf("Tagged int value out of range: %d", val)
+	}
+	binary.BigEndian.PutUint32(t[:], val)
+	return pkt.AddAttrRawTagged(name, tag, t[:])
+}
+
+// MustAddAttrIntTagged - add int tagged Attr to packet, value is 24 bit
+func (pkt *Packet) MustAddAttrIntTagged(name string, tag byte, val uint32) {
+	if err := pkt.AddAttrIntTagged(name, tag, val); err != nil {
+		panic(err)
+	}
+}
+
+// addAttrType - add Attr to packet with dictionary data type check
+func (pkt *Packet) addAttrType(name string, dtyp int, val []byte) error {
+	attr, err := pkt.newAttr(name, val)
+	if err != nil {
+		return err
+	}
+	if attr.atyp.Dtyp != dtyp {
+		return fmt.Errorf("Attribute %s has type %s, not %s", name, zdict.TypeName(attr.atyp.Dtyp), zdict.TypeName(dtyp))
+	}
+	pkt.attr = append(pkt.attr, attr)
+	return nil
+}
+
+// AddAttrIP6 - add IPv6 Attr to packet
+func (pkt *Packet) AddAttrIP6(name string, val net.IP) error {
+	t := val.To16()
+	if t == nil || val.To4() != nil {
+		return fmt.Errorf("Argument is not IPv6")
+	}
+	return pkt.addAttrType(name, zdict.TypeIP6, t)
+}
+
+// MustAddAttrIP6 - add IPv6 Attr to packet
+func (pkt *Packet) MustAddAttrIP6(name string, val net.IP) {
+	if err := pkt.AddAttrIP6(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddAttrIP4Prefix - add IPv4 prefix Attr to packet
+func (pkt *Packet) AddAttrIP4Prefix(name string, val *net.IPNet) error {
+	t, err := encodePrefix(zdict.TypeIP4Pfx, val)
+	if err != nil {
+		return err
+	}
+	return pkt.addAttrType(name, zdict.TypeIP4Pfx, t)
+}
+
+// MustAddAttrIP4Prefix - add IPv4 prefix Attr to packet
+func (pkt *Packet) MustAddAttrIP4Prefix(name string, val *net.IPNet) {
+	if err := pkt.AddAttrIP4Prefix(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddAttrIP6Prefix - add IPv6 prefix Attr to packet
+func (pkt *Packet) AddAttrIP6Prefix(name string, val *net.IPNet) error {
+	t, err := encodePrefix(zdict.TypeIP6Pfx, val)
+	if err != nil {
+		return err
+	}
+	return pkt.addAttrType(name, zdict.TypeIP6Pfx, t)
+}
+
+// MustAddAttrIP6Prefix - add IPv6 prefix Attr to packet
+func (pkt *Packet) MustAddAttrIP6Prefix(name string, val *net.IPNet) {
+	if err := pkt.AddAttrIP6Prefix(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddAttrTime - add date Attr to packet
+func (pkt *Packet) AddAttrTime(name string, val time.Time) error {
+	t, err := encodeTime(val)
+	if err != nil {
+		return err
+	}
+	return pkt.addAttrType(name, zdict.TypeDate, t)
+}
+
+// MustAddAttrTime - add date Attr to packet
+func (pkt *Packet) MustAddAttrTime(name string, val time.Time) {
+	if err := pkt.AddAttrTime(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddAttrInt64 - add int64 Attr to packet
+func (pkt *Packet) AddAttrInt64(name string, val uint64) error {
+	var t [8]byte
+
+	binary.BigEndian.PutUint64(t[:], val)
+	return pkt.addAttrType(name, zdict.TypeInt64, t[:])
+}
+
+// MustAddAttrInt64 - add int64 Attr to packet
+func (pkt *Packet) MustAddAttrInt64(name string, val uint64) {
+	if err := pkt.AddAttrInt64(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddAttrIfID - add Interface-Id Attr to packet
+func (pkt *Packet) AddAttrIfID(name string, val net.HardwareAddr) error {
+	if len(val) != 8 {
+		return fmt.Errorf("Interface-Id len must be 8, got %d", len(val))
+	}
+	return pkt.addAttrType(name, zdict.TypeIfID, append([]byte(nil), val...))
+}
+
+// MustAddAttrIfID - add Interface-Id Attr to packet
+func (pkt *Packet) MustAddAttrIfID(name string, val net.HardwareAddr) {
+	if err := pkt.AddAttrIfID(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddAttrMAC - add MAC address Attr to packet
+func (pkt *Packet) AddAttrMAC(name string, val net.HardwareAddr) error {
+	if len(val) != 6 {
+		return fmt.Errorf("MAC address len must be 6, got %d", len(val))
+	}
+	return pkt.addAttrType(name, zdict.TypeEth, append([]byte(nil), val...))
+}
+
+// MustAddAttrMAC - add MAC address Attr to packet
+func (pkt *Packet) MustAddAttrMAC(name string, val net.HardwareAddr) {
+	if err := pkt.AddAttrMAC(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddAttrByte - add byte Attr to packet
+func (pkt *Packet) AddAttrByte(name string, val byte) error {
+	return pkt.addAttrType(name, zdict.TypeByte, []byte{val})
+}
+
+// MustAddAttrByte - add byte Attr to packet
+func (pkt *Packet) MustAddAttrByte(name string, val byte) {
+	if err := pkt.AddAttrByte(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddAttrShort - add short Attr to packet
+func (pkt *Packet) AddAttrShort(name string, val uint16) error {
+	var t [2]byte
+
+	binary.BigEndian.PutUint16(t[:], val)
+	return pkt.addAttrType(name, zdict.TypeShort, t[:])
+}
+
+// MustAddAttrShort - add short Attr to packet
+func (pkt *Packet) MustAddAttrShort(name string, val uint16) {
+	if err := pkt.AddAttrShort(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddAttrSInt - add signed int Attr to packet
+func (pkt *Packet) AddAttrSInt(name string, val int32) error {
+	var t [4]byte
+
+	binary.BigEndian.PutUint32(t[:], uint32(val))
+	return pkt.addAttrType(name, zdict.TypeSInt, t[:])
+}
+
+// MustAddAttrSInt - add signed int Attr to packet
+func (pkt *Packet) MustAddAttrSInt(name string, val int32) {
+	if err := pkt.AddAttrSInt(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// newAttrVal - create Attr by name, value is encoded according to dictionary type
+func (pkt *Packet) newAttrVal(name string, val interface{}) (*Attr, error) {
+	var data []byte
+
+	attr, err := pkt.newAttr(name, nil)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = encodeValue(pkt.GetDict(), attr.atyp, val); err != nil {
+		return nil, fmt.Errorf("Attribute %s: %v", name, err)
+	}
+	if err = attr.setData(data); err != nil {
+		return nil, err
+	}
+	return attr, nil
+}
+
+// AddAttr - add Attr to packet, value is encoded according to dictionary type
+func (pkt *Packet) AddAttr(name string, val interface{}) error {
+	attr, err := pkt.newAttrVal(name, val)
+	if err != nil {
+		return err
+	}
+	pkt.attr = append(pkt.attr, attr)
+	return nil
+}
+
+// MustAddAttr - add Attr to packet, value is encoded according to dictionary type
+func (pkt *Packet) MustAddAttr(name string, val interface{}) {
+	if err := pkt.AddAttr(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// findEnum - find attr and its named value in dictionary
+func (pkt *Packet) findEnum(name, val string) (*zdict.AttrData, *zdict.ValueData, error) {
+	var (
+		ad *zdict.AttrData
+		vd *zdict.ValueData
+	)
+
+	if ad = pkt.GetDict().FindAttrName(name); ad == nil {
+		return nil, nil, fmt.Errorf("Attribute %s not found", name)
+	}
+	if vd = pkt.GetDict().FindValueName(ad, val); vd == nil {
+		return nil, nil, fmt.Errorf("Value %s for attribute %s not found", val, name)
+	}
+	return ad, vd, nil
+}
+
+// AddAttrEnum - add Attr with named value to packet
+func (pkt *Packet) AddAttrEnum(name, val string) error {
+	ad, vd, err := pkt.findEnum(name, val)
+	if err != nil {
+		return err
+	}
+	return pkt.AddAttr(ad.Name, vd.Val)
+}
+
+// MustAddAttrEnum - add Attr with named value to packet
+func (pkt *Packet) MustAddAttrEnum(name, val string) {
+	if err := pkt.AddAttrEnum(name, val); err != nil {
+		panic(err)
+	}
+}
+
+// AddAttrEnumTagged - add tagged Attr with named value to packet
+func (pkt *Packet) AddAttrEnumTagged(name string, tag byte, val string) error {
+	ad, vd, err := pkt.findEnum(name, val)
+	if err != nil {
+		return err
+	}
+	return pkt.AddAttrIntTagged(ad.Name, tag, vd.Val)
+}
+
+// MustAddAttrEnumTagged - add tagged Attr with named value to packet
+func (pkt *Packet) MustAddAttrEnumTagged(name string, tag byte, val string) {
+	if err := pkt.AddAttrEnumTagged(name, tag, val); err != nil {
+		panic(err)
+	}
+}
+
+// GetAttrConcat - return joined data of all attrs with given name
+func (pkt *Packet) GetAttrConcat(name string) []byte {
+	var (
+		ad   *zdict.AttrData
+		attr *Attr
+		data []byte
+	)
+
+	if ad = pkt.GetDict().FindAttrName(name); ad == nil {
+		return nil
+	}
+	for _, attr = range pkt.attr {
+		if attr.atyp == ad {
+			attr.decrypt(pkt)
+			data = append(data, attr.data...)
+		}
+	}
+	return data
+}
@@ -0,0 +1,437 @@
+package zradius
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// testDecode - decode raw packet data with RFC 2865 §7.1 secret
+func testDecode(t *testing.T, data []byte) *Packet {
+	t.Helper()
+	pkt := &Packet{data: data, secret: testSecret}
+	if err := pkt.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	return pkt
+}
+
+// testDict - Default dictionary clone with dictionary file text loaded
+func testDict(t *testing.T, text string) *zdict.Dictionary {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dictionary")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := zdict.Default.Clone()
+	if err := d.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestHashAuth(t *testing.T) {
+	for code, want := range map[byte]string{
+		zdict.AccountingRequest: "9c0cbb5db15cfebec247c99d3606c71c",
+		zdict.CoARequest:        "573942a009828e0e4ffea09fce873dee",
+		zdict.DisconnectRequest: "211208d7fc3759b895dbdb2a308d6ced",
+	} {
+		pkt := &Packet{code: code, id: 7, secret: testSecret}
+		pkt.MustAddAttrStr("User-Name", "bob")
+		pkt.MustAddAttr("Acct-Status-Type", 1)
+		if err := pkt.Encode(true); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(pkt.data[4:MinPLen], mustHex(want)) || !bytes.Equal(pkt.auth[:], mustHex(want)) {
+			t.Fatalf("code %d: got %x, want %s", code, pkt.data[4:MinPLen], want)
+		}
+	}
+	// Access-Request authenticator is random
+	a := RadNew(zdict.AccessRequest)
+	b := RadNew(zdict.AccessRequest)
+	a.Encode(true)
+	b.Encode(true)
+	if a.auth == zeroAuth || a.auth == b.auth {
+		t.Fatal("Access-Request authenticator is not random")
+	}
+}
+
+func TestTypedAttrs(t *testing.T) {
+	d := testDict(t, `ATTRIBUTE Test-Int64 240 integer64
+ATTRIBUTE Test-Byte 239 byte
+ATTRIBUTE Test-Short 238 short
+ATTRIBUTE Test-SInt 237 signed
+ATTRIBUTE Test-Eth 236 ether
+ATTRIBUTE Test-IP4-Prefix 235 ipv4prefix
+`)
+	_, n4, _ := net.ParseCIDR("10.1.0.0/16")
+	_, n6, _ := net.ParseCIDR("2001:db8:1::/48")
+	ts := time.Unix(1700000000, 0)
+	pkt := RadNew(zdict.AccountingRequest)
+	pkt.SetDict(d)
+	pkt.MustAddAttrIP6("Framed-IPv6-Address", net.ParseIP("2001:db8::1"))
+	pkt.MustAddAttrIP4Prefix("Test-IP4-Prefix", n4)
+	pkt.MustAddAttrIP6Prefix("Delegated-IPv6-Prefix", n6)
+	pkt.MustAddAttrTime("Event-Timestamp", ts)
+	pkt.MustAddAttrIfID("Framed-Interface-Id", net.HardwareAddr{1, 2, 3, 4, 5, 6, 7, 8})
+	pkt.MustAddAttrInt64("Test-Int64", 1<<40)
+	pkt.MustAddAttrByte("Test-Byte", 7)
+	pkt.MustAddAttrShort("Test-Short", 300)
+	pkt.MustAddAttrSInt("Test-SInt", -5)
+	pkt.MustAddAttrMAC("Test-Eth", net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55})
+	if err := pkt.Encode(true); err != nil {
+		t.Fatal(err)
+	}
+	r := &Packet{data: pkt.data, dict: d}
+	if err := r.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"2001:db8::1", "10.1.0.0/16", "2001:db8:1::/48", ts.String(), "01:02:03:04:05:06:07:08",
+		"1099511627776", "7", "300", "-5", "00:11:22:33:44:55"} {
+		if v, err := r.attr[i].GetValue(r); err != nil || fmt.Sprint(v) != want {
+			t.Fatalf("%s: got %v, %v, want %s", r.attr[i].GetName(), v, err, want)
+		}
+	}
+	// dictionary type is checked
+	for _, err := range []error{
+		pkt.AddAttrIP6("NAS-IP-Address", net.ParseIP("2001:db8::1")),
+		pkt.AddAttrIP6("Framed-IPv6-Address", net.ParseIP("10.0.0.1")),
+		pkt.AddAttrIP4Prefix("Framed-IPv6-Prefix", n4),
+		pkt.AddAttrIP6Prefix("Framed-IPv6-Prefix", n4),
+		pkt.AddAttrTime("User-Name", ts),
+		pkt.AddAttrTime("Event-Timestamp", time.Unix(-1, 0)),
+		pkt.AddAttrIfID("Framed-Interface-Id", net.HardwareAddr{1, 2}),
+		pkt.AddAttrMAC("Test-Eth", net.HardwareAddr{1, 2}),
+		pkt.AddAttrByte("Test-Short", 1),
+	} {
+		if err == nil {
+			t.Fatal("bad attr is added")
+		}
+	}
+}
+
+func TestPacketDict(t *testing.T) {
+	d := testDict(t, "ATTRIBUTE Own-Attr 250 integer\nVALUE Own-Attr Foo 5\n")
+	pkt := RadNew(zdict.AccountingRequest)
+	if pkt.GetDict() != zdict.Default || pkt.AddAttr("Own-Attr", 1) == nil {
+		t.Fatal("packet uses own dictionary by default")
+	}
+	pkt.SetDict(d)
+	pkt.MustAddAttr("Own-Attr", "Foo")
+	if err := pkt.Encode(true); err != nil {
+		t.Fatal(err)
+	}
+	r := &Packet{data: pkt.data}
+	if err := r.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if r.attr[0].atyp != nil || r.attr[0].GetName() != "250" {
+		t.Fatal("attr is found in Default")
+	}
+	r = &Packet{data: pkt.data, dict: d}
+	if err := r.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if a := r.GetAttr("Own-Attr"); a.GetEData(r) != uint32(5) || a.GetEnum(r) != "Foo" {
+		t.Fatalf("got %v", a.GetEData(r))
+	}
+	if rep := r.RadReply(zdict.AccountingResponse); rep.GetDict() != d {
+		t.Fatal("reply dictionary is not inherited")
+	}
+}
+
+// testBytes - n bytes of counter data
+func testBytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+func TestExtended(t *testing.T) {
+	d := testDict(t, `VENDOR Acme 999
+ATTRIBUTE Ext-Str 241.10 string
+ATTRIBUTE Long-Oct 245.3 octets
+ATTRIBUTE Acme-Evs 241.26.999.7 string
+BEGIN-VENDOR Acme format=Extended-Vendor-Specific-5
+ATTRIBUTE Acme-Long 8 octets
+END-VENDOR Acme
+`)
+	big := testBytes(600)
+	pkt := RadNew(zdict.AccessRequest)
+	pkt.SetDict(d)
+	pkt.MustAddAttr("Ext-Str", "hello")
+	pkt.MustAddAttr("Long-Oct", big)
+	pkt.MustAddAttr("Acme-Evs", "evs")
+	pkt.MustAddAttr("Acme-Long", big[:300])
+	pkt.MustAddAttr("Frag-Status", 1)
+	if pkt.AddAttr("Ext-Str", string(big[:253])) == nil || pkt.AddAttr("Acme-Evs", string(big[:248])) == nil {
+		t.Fatal("too long extended attr is added")
+	}
+	if err := pkt.Encode(true); err != nil {
+		t.Fatal(err)
+	}
+	// Ext-Str: 241, 8, 10, "hello"
+	if !bytes.Equal(pkt.data[MinPLen:MinPLen+8], []byte{241, 8, 10, 'h', 'e', 'l', 'l', 'o'}) {
+		t.Fatalf("got %x", pkt.data[MinPLen:MinPLen+8])
+	}
+	// Long-Oct: 3 fragments, More flag in first two
+	p := MinPLen + 8
+	for i, l := range []int{255, 255, 4 + 600 - 2*251} {
+		if pkt.data[p] != 245 || int(pkt.data[p+1]) != l || pkt.data[p+2] != 3 || (pkt.data[p+3] == extFlagMore) != (i < 2) {
+			t.Fatalf("fragment %d: %x", i, pkt.data[p:p+4])
+		}
+		p += l
+	}
+	r := &Packet{data: pkt.data, dict: d}
+	if err := r.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.attr) != 5 {
+		t.Fatalf("got %d attrs", len(r.attr))
+	}
+	for i, want := range []interface{}{"hello", big, "evs", big[:300], "1"} {
+		if v := r.attr[i].GetEData(r); fmt.Sprint(v) != fmt.Sprint(want) {
+			t.Fatalf("%s: got %v", r.attr[i].GetName(), v)
+		}
+	}
+	if a := r.attr[2]; a.typ != 241 || a.ext != zdict.ExtEVS || a.vid != 999 || a.vtyp != 7 {
+		t.Fatalf("EVS attr %+v", a)
+	}
+}
+
+func TestExtendedErrors(t *testing.T) {
+	hdr := func(attrs ...byte) []byte {
+		attrs = append([]byte{1, 3, 'x'}, attrs...) // User-Name
+		data := append([]byte{zdict.AccessRequest, 1, 0, byte(MinPLen + len(attrs))}, make([]byte, 16)...)
+		return append(data, attrs...)
+	}
+	for name, data := range map[string][]byte{
+		"no ext type":   hdr(241, 2),
+		"no flags":      hdr(245, 3, 1),
+		"short evs":     hdr(241, 7, 26, 0, 0, 3, 231),
+		"last has more": hdr(245, 5, 1, 0x80, 'a'),
+		"not continued": hdr(245, 5, 1, 0x80, 'a', 245, 5, 2, 0, 'b'),
+		"other attr":    hdr(245, 5, 1, 0x80, 'a', 1, 3, 'b'),
+	} {
+		pkt := &Packet{data: data}
+		if err := pkt.Decode(); err == nil {
+			t.Fatalf("%s: no error", name)
+		}
+	}
+}
+
+// testPacket - raw Access-Request with attrs
+func testPacket(attrs ...byte) []byte {
+	data := append([]byte{zdict.AccessRequest, 1, 0, byte(MinPLen + len(attrs))}, make([]byte, 16)...)
+	return append(data, attrs...)
+}
+
+func TestConcat(t *testing.T) {
+	big := testBytes(600)
+	pkt := RadNew(zdict.AccessRequest)
+	pkt.MustAddAttr("User-Name", "x")
+	pkt.MustAddAttr("EAP-Message", big)
+	pkt.MustAddAttr("EAP-Message", big[:10])
+	pkt.MustAddAttrRaw("EAP-Message", big[:253])
+	pkt.AddMsgAuth()
+	for _, err := range []error{
+		pkt.AddAttrRaw("Class", big[:254]),
+		pkt.AddAttrRaw("Tunnel-Private-Group-Id", big[:253]), // tag byte
+		pkt.AddAttrRaw("Mikrotik-Group", big[:248]),
+	} {
+		if err == nil {
+			t.Fatal("too long attr is added")
+		}
+	}
+	if err := pkt.Encode(true); err != nil {
+		t.Fatal(err)
+	}
+	if len(pkt.data) != MinPLen+18+3+2*3+600+2+10+2+253 {
+		t.Fatalf("len %d", len(pkt.data))
+	}
+	r := testDecode(t, pkt.data)
+	if len(r.attr) != 3 || len(r.GetAttr("EAP-Message").GetData()) != 863 || !bytes.Equal(r.GetAttrConcat("EAP-Message"), r.attr[2].data) {
+		t.Fatalf("got %d attrs", len(r.attr))
+	}
+	if err := r.Verify(nil); err != nil {
+		t.Fatal(err)
+	}
+	// attrs are joined only if they are consecutive
+	r = testDecode(t, testPacket(79, 3, 'a', 1, 3, 'x', 79, 3, 'b'))
+	if len(r.attr) != 3 || string(r.GetAttrConcat("EAP-Message")) != "ab" {
+		t.Fatalf("got %d attrs", len(r.attr))
+	}
+	// not concat attrs are not joined
+	r = testDecode(t, testPacket(25, 3, 'a', 25, 3, 'b'))
+	if len(r.attr) != 2 {
+		t.Fatalf("got %d attrs", len(r.attr))
+	}
+}
+
+func TestConcatFirstFragment(t *testing.T) {
+	// first attr is Long-Extended fragment with More flag
+	r := testDecode(t, testPacket(245, 6, 1, 0x80, 'a', 'b', 245, 5, 1, 0, 'c'))
+	if len(r.attr) != 1 || string(r.attr[0].data) != "abc" {
+		t.Fatalf("got %d attrs", len(r.attr))
+	}
+	// first attr is WiMAX fragment with continuation flag
+	r = &Packet{
+		data: testPacket(26, 11, 0, 0, 0x60, 0xb5, 1, 5, 0x80, 'a', 'b', 26, 10, 0, 0, 0x60, 0xb5, 1, 4, 0, 'c'),
+		dict: testDict(t, "VENDOR WiMAX 24757 format=1,1,c\n"),
+	}
+	if err := r.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.attr) != 1 || string(r.attr[0].data) != "abc" {
+		t.Fatalf("got %d attrs", len(r.attr))
+	}
+	// last fragment has More flag
+	if err := (&Packet{data: testPacket(245, 6, 1, 0x80, 'a', 'b')}).Decode(); err == nil {
+		t.Fatal("no error")
+	}
+}
+
+func TestConcatVSA(t *testing.T) {
+	d := testDict(t, `VENDOR Acme 999
+BEGIN-VENDOR Acme
+ATTRIBUTE Acme-Blob 1 octets concat
+ATTRIBUTE Acme-Other 2 string
+END-VENDOR Acme
+`)
+	pkt := RadNew(zdict.AccessRequest)
+	pkt.SetDict(d)
+	pkt.MustAddAttr("Acme-Blob", "a")
+	pkt.MustAddAttr("Acme-Other", "b")
+	pkt.MustAddAttr("Acme-Blob", "c")
+	pkt.MustAddAttr("EAP-Message", "d")
+	pkt.MustAddAttr("Acme-Other", "e")
+	if err := pkt.Encode(true); err != nil {
+		t.Fatal(err)
+	}
+	r := &Packet{data: pkt.data, dict: d}
+	if err := r.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, a := range r.attr {
+		got = append(got, a.GetName()+"="+string(a.data))
+	}
+	if fmt.Sprint(got) != "[Acme-Blob=a Acme-Other=b Acme-Blob=c EAP-Message=d Acme-Other=e]" {
+		t.Fatal(got)
+	}
+}
+
+func TestEditAttrs(t *testing.T) {
+	pkt := RadNew(zdict.AccessAccept)
+	pkt.MustAddAttr("Class", "a")
+	pkt.MustAddAttr("Reply-Message", "m1")
+	pkt.MustAddAttr("Class", "b")
+	pkt.MustAddAttr("Mikrotik-Group", "g")
+	pkt.MustAddAttr("Class", "c")
+	names := func() string {
+		var r []string
+		pkt.RangeAttrs(func(a *Attr) bool {
+			r = append(r, a.GetName()+"="+string(a.GetData()))
+			return true
+		})
+		return fmt.Sprint(r)
+	}
+	if pkt.Count("Class") != 3 || len(pkt.GetAttrs("class")) != 3 || pkt.Count("User-Name") != 0 || pkt.GetAttrs("No-Such-Attr") != nil {
+		t.Fatal("count")
+	}
+	if a := pkt.GetAttrs("Class"); string(a[0].data) != "a" || string(a[2].data) != "c" {
+		t.Fatal("order")
+	}
+	n := 0
+	pkt.RangeAttrs(func(a *Attr) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Fatal("RangeAttrs is not stopped")
+	}
+	pkt.MustReplaceAttr("Class", "z")
+	if s := names(); s != "[Class=z Reply-Message=m1 Mikrotik-Group=g]" {
+		t.Fatal(s)
+	}
+	if pkt.DelAttr("Reply-Message") != 1 || pkt.DelAttr("Reply-Message") != 0 || pkt.DelAttr("No-Such-Attr") != 0 {
+		t.Fatal("del")
+	}
+	pkt.MustReplaceAttr("Reply-Message", "new")
+	if s := names(); s != "[Class=z Mikrotik-Group=g Reply-Message=new]" {
+		t.Fatal(s)
+	}
+	if pkt.ReplaceAttr("NAS-Port", "x") == nil || pkt.ReplaceAttr("No-Such-Attr", 1) == nil {
+		t.Fatal("bad value is replaced")
+	}
+	if err := pkt.Encode(false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVSAFormat(t *testing.T) {
+	d := testDict(t, `VENDOR USR 429 format=4,0
+VENDOR Lucent 4846 format=2,1
+VENDOR Starent 8164 format=2,2
+VENDOR WiMAX 24757 format=1,1,c
+ATTRIBUTE USR-Big 0x9000 integer USR
+ATTRIBUTE Lucent-Str 300 string Lucent
+ATTRIBUTE Starent-Str 400 string Starent
+ATTRIBUTE WiMAX-AAA-Session-Id 4 octets WiMAX
+`)
+	big := testBytes(600)
+	pkt := RadNew(zdict.AccessRequest)
+	pkt.SetDict(d)
+	pkt.MustAddAttr("USR-Big", 7)
+	pkt.MustAddAttr("Lucent-Str", "abc")
+	pkt.MustAddAttr("Starent-Str", "de")
+	pkt.MustAddAttr("WiMAX-AAA-Session-Id", big)
+	if pkt.AddAttr("Lucent-Str", string(big[:247])) == nil {
+		t.Fatal("too long VSA is added")
+	}
+	if err := pkt.Encode(true); err != nil {
+		t.Fatal(err)
+	}
+	p := MinPLen
+	for _, want := range [][]byte{
+		{26, 14, 0, 0, 0x01, 0xad, 0, 0, 0x90, 0, 0, 0, 0, 7},          // 4 byte type, no len
+		{26, 12, 0, 0, 0x12, 0xee, 0x01, 0x2c, 6, 'a', 'b', 'c'},       // 2 byte type, 1 byte len
+		{26, 12, 0, 0, 0x1f, 0xe4, 0x01, 0x90, 0, 6, 'd', 'e'},         // 2 byte type, 2 byte len
+		{26, 255, 0, 0, 0x60, 0xb5, 4, 249, 0x80},                      // WiMAX first fragment
+		{26, 255, 0, 0, 0x60, 0xb5, 4, 249, 0x80},                      // second fragment
+		{26, 9 + 600 - 2*246, 0, 0, 0x60, 0xb5, 4, 3 + 600 - 2*246, 0}, // last fragment
+	} {
+		if !bytes.Equal(pkt.data[p:p+len(want)], want) {
+			t.Fatalf("offset %d: got %x, want %x", p, pkt.data[p:p+len(want)], want)
+		}
+		p += int(want[1])
+	}
+	r := &Packet{data: pkt.data, dict: d}
+	if err := r.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.attr) != 4 {
+		t.Fatalf("got %d attrs", len(r.attr))
+	}
+	for i, want := range []interface{}{uint32(7), "abc", "de", big} {
+		if v := r.attr[i].GetEData(r); fmt.Sprint(v) != fmt.Sprint(want) {
+			t.Fatalf("%s: got %v", r.attr[i].GetName(), v)
+		}
+	}
+	if a := r.attr[0]; a.vtyp != 0x9000 || a.GetName() != "USR-Big" {
+		t.Fatalf("USR attr %+v", a)
+	}
+	// VendorLen and VSA len mismatch
+	bad := testPacket(26, 12, 0, 0, 0x12, 0xee, 0x01, 0x2c, 7, 'a', 'b', 'c')
+	if err := (&Packet{data: bad, dict: d}).Decode(); err == nil {
+		t.Fatal("no error")
+	}
+}
@@ -0,0 +1,241 @@
+package zradius
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Pool modes
+const (
+	PoolFailover   = iota // first alive server in order of adding
+	PoolRoundRobin        // alive servers in turn
+	PoolWeighted          // alive servers in turn according to weight
+	PoolSticky            // server selected by User-Name hash
+)
+
+// Pool defaults
+const (
+	DefMaxFails     = 3               // consecutive timeouts to mark server dead
+	DefProbeTimeout = 5 * time.Second // Status-Server probe timeout
+)
+
+// Pool errors
+var (
+	ErrNoServers = errors.New("No servers in pool")
+)
+
+// PoolStats - pool server statistics
+type PoolStats struct {
+	Addr     string        // server addr
+	Weight   int           // server weight
+	Alive    bool          // server is alive
+	Requests uint64        // requests sent
+	Replies  uint64        // replies received
+	Timeouts uint64        // requests without reply
+	Errors   uint64        // other errors
+	Probes   uint64        // Status-Server probes of dead server
+	LastRTT  time.Duration // last request time including retransmissions
+}
+
+// pool server
+type poolServer struct {
+	c     *RadClient // server client
+	fails int        // consecutive timeouts
+	cur   int        // current weight for PoolWeighted
+	st    PoolStats  // statistics
+}
+
+// Pool - servers pool with failover and load balancing, dead servers are revived by Probe
+type Pool struct {
+	Mode     int // pool mode
+	MaxFails int // consecutive timeouts to mark server dead, DefMaxFails if not set
+	mu       sync.Mutex
+	servers  []*poolServer
+	rr       int // next server for PoolRoundRobin
+}
+
+// NewPool - create empty pool
+func NewPool(mode int) *Pool {
+	return &Pool{
+		Mode: mode,
+	}
+}
+
+// AddServer - add server client to pool, weight is used by PoolWeighted, 1 if not set
+func (p *Pool) AddServer(c *RadClient, weight int) {
+	if weight <= 0 {
+		weight = 1
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.servers = append(p.servers, &poolServer{
+		c: c,
+		st: PoolStats{
+			Addr:   c.GetAddr().String(),
+			Weight: weight,
+			Alive:  true,
+		},
+	})
+}
+
+// Stats - get statistics for all servers in order of adding
+func (p *Pool) Stats() []PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r := make([]PoolStats, len(p.servers))
+	for i, s := range p.servers {
+		r[i] = s.st
+	}
+	return r
+}
+
+// order - servers to try for request, alive servers first
+func (p *Pool) order(pkt *Packet) []*poolServer {
+	var (
+		alive, dead []*poolServer
+		start       int
+	)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.servers)
+	switch p.Mode {
+	case PoolRoundRobin:
+		start = p.rr % n
+		p.rr++
+	case PoolSticky:
+		if a := pkt.GetAttr("User-Name"); a != nil {
+			h := fnv.New32a()
+			h.Write(a.GetData())
+			start = int(h.Sum32() % uint32(n))
+		}
+	case PoolWeighted: // smooth weighted round-robin
+		var (
+			best  *poolServer
+			total int
+		)
+		for i, s := range p.servers {
+			if !s.st.Alive {
+				continue
+			}
+			s.cur += s.st.Weight
+			total += s.st.Weight
+			if best == nil || s.cur > best.cur {
+				best, start = s, i
+			}
+		}
+		if best != nil {
+			best.cur -= total
+		}
+	}
+	for i := 0; i < n; i++ {
+		s := p.servers[(start+i)%n]
+		if s.st.Alive {
+			alive = append(alive, s)
+		} else {
+			dead = append(dead, s)
+		}
+	}
+	return append(alive, dead...)
+}
+
+// result - update server statistics and state after request, must not be called if caller's ctx is done
+func (p *Pool) result(s *poolServer, err error, rtt time.Duration) {
+	maxFails := p.MaxFails
+	if maxFails <= 0 {
+		maxFails = DefMaxFails
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s.st.Requests++
+	switch {
+	case err == nil:
+		s.st.Replies++
+		s.st.LastRTT = rtt
+		s.st.Alive = true
+		s.fails = 0
+	case errors.Is(err, ErrTimeout):
+		s.st.Timeouts++
+		if s.fails++; s.fails >= maxFails {
+			s.st.Alive = false
+		}
+	default:
+		s.st.Errors++
+	}
+}
+
+// Exchange - send request to pool servers according to pool mode, next server is tried on timeout
+// or error, dead servers are tried only if there are no alive ones, request is sent with secret of each
+// server and is not changed
+func (p *Pool) Exchange(ctx context.Context, pkt *Packet) (*Packet, error) {
+	p.mu.Lock()
+	n := len(p.servers)
+	p.mu.Unlock()
+	if n == 0 {
+		return nil, ErrNoServers
+	}
+	err := ErrNoServers
+	for _, s := range p.order(pkt) {
+		req := *pkt
+		req.secret = s.c.secret
+		t := time.Now()
+		var rep *Packet
+		rep, err = s.c.Exchange(ctx, &req)
+		if ctx.Err() != nil { // cancelled by caller, server is not guilty
+			return nil, ctx.Err()
+		}
+		p.result(s, err, time.Since(t))
+		if err == nil {
+			return rep, nil
+		}
+	}
+	return nil, err
+}
+
+// probe - send Status-Server to server (RFC 5997)
+func (p *Pool) probe(ctx context.Context, s *poolServer) {
+	ctx, cancel := context.WithTimeout(ctx, DefProbeTimeout)
+	defer cancel()
+	_, rtt, err := s.c.Status(ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s.st.Probes++
+	if err == nil {
+		s.st.Alive = true
+		s.st.LastRTT = rtt
+		s.fails = 0
+	}
+}
+
+// Probe - probe dead servers with Status-Server every interval until ctx is done, server is alive on reply
+func (p *Pool) Probe(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		var dead []*poolServer
+		p.mu.Lock()
+		for _, s := range p.servers {
+			if !s.st.Alive {
+				dead = append(dead, s)
+			}
+		}
+		p.mu.Unlock()
+		var wg sync.WaitGroup
+		for _, s := range dead {
+			wg.Add(1)
+			go func(s *poolServer) {
+				defer wg.Done()
+				p.probe(ctx, s)
+			}(s)
+		}
+		wg.Wait()
+	}
+}
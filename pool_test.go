@@ -0,0 +1,147 @@
+package zradius
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// testPoolClient - client for test server without retransmissions
+func testPoolClient(t *testing.T, f func(req *Packet) *Packet) *RadClient {
+	t.Helper()
+	c := testClient(t, testUDPServer(t, f).LocalAddr())
+	c.MRC = -1
+	return c
+}
+
+// blackhole - server without replies
+func blackhole(*Packet) *Packet {
+	return nil
+}
+
+func TestPoolFailover(t *testing.T) {
+	p := NewPool(PoolFailover)
+	p.MaxFails = 2
+	p.AddServer(testPoolClient(t, blackhole), 1)
+	p.AddServer(testPoolClient(t, echoHandler), 1)
+	req := RadNew(zdict.AccessRequest)
+	req.MustAddAttrStr("User-Name", "bob")
+	for i := 0; i < 3; i++ {
+		rep, err := p.Exchange(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(rep.GetAttr("User-Name").GetData()) != "bob" {
+			t.Fatalf("got %s", rep)
+		}
+		if req.secret != nil {
+			t.Fatal("request is changed by Exchange")
+		}
+	}
+	st := p.Stats()
+	if st[0].Alive || st[0].Requests != 2 || st[0].Timeouts != 2 {
+		t.Fatalf("blackholed server: %+v", st[0])
+	}
+	if !st[1].Alive || st[1].Requests != 3 || st[1].Replies != 3 {
+		t.Fatalf("alive server: %+v", st[1])
+	}
+}
+
+func TestPoolTimeout(t *testing.T) {
+	// MRD is server timeout
+	c := testPoolClient(t, blackhole)
+	c.MRC = 1000
+	c.MRD = 100 * time.Millisecond
+	p := NewPool(PoolFailover)
+	p.MaxFails = 1
+	p.AddServer(c, 1)
+	if _, err := p.Exchange(context.Background(), RadNew(zdict.AccessRequest)); err != ErrTimeout {
+		t.Fatalf("got %v", err)
+	}
+	if st := p.Stats()[0]; st.Alive || st.Timeouts != 1 {
+		t.Fatalf("got %+v", st)
+	}
+	// caller deadline is not server fault
+	p = NewPool(PoolFailover)
+	p.MaxFails = 1
+	p.AddServer(testPoolClient(t, blackhole), 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Exchange(ctx, RadNew(zdict.AccessRequest)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v", err)
+	}
+	if st := p.Stats()[0]; !st.Alive || st.Requests != 0 {
+		t.Fatalf("got %+v", st)
+	}
+	if _, err := NewPool(PoolFailover).Exchange(context.Background(), RadNew(zdict.AccessRequest)); err != ErrNoServers {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestPoolBalance(t *testing.T) {
+	for _, tc := range []struct {
+		mode    int
+		weights []int
+		want    []uint64
+	}{
+		{PoolRoundRobin, []int{1, 1}, []uint64{4, 4}},
+		{PoolWeighted, []int{3, 1}, []uint64{6, 2}},
+		{PoolSticky, []int{1, 1}, nil},
+	} {
+		p := NewPool(tc.mode)
+		for _, w := range tc.weights {
+			p.AddServer(testPoolClient(t, echoHandler), w)
+		}
+		for i := 0; i < 8; i++ {
+			req := RadNew(zdict.AccessRequest)
+			req.MustAddAttrStr("User-Name", "bob")
+			if _, err := p.Exchange(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		st := p.Stats()
+		if tc.want == nil { // same server for the same User-Name
+			if st[0].Replies+st[1].Replies != 8 || st[0].Replies%8 != 0 {
+				t.Fatalf("mode %d: got %d, %d", tc.mode, st[0].Replies, st[1].Replies)
+			}
+			continue
+		}
+		for i, s := range st {
+			if s.Replies != tc.want[i] {
+				t.Fatalf("mode %d: server %d got %d replies, want %d", tc.mode, i, s.Replies, tc.want[i])
+			}
+		}
+	}
+}
+
+func TestPoolProbe(t *testing.T) {
+	p := NewPool(PoolFailover)
+	p.MaxFails = 1
+	p.AddServer(testPoolClient(t, func(req *Packet) *Packet { // only Status-Server is answered
+		if req.GetCode() == zdict.StatusServer {
+			return StatusReply(req)
+		}
+		return nil
+	}), 1)
+	if _, err := p.Exchange(context.Background(), RadNew(zdict.AccessRequest)); err != ErrTimeout {
+		t.Fatalf("got %v", err)
+	}
+	if p.Stats()[0].Alive {
+		t.Fatal("server is alive")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Probe(ctx, 20*time.Millisecond)
+	for end := time.Now().Add(2 * time.Second); time.Now().Before(end); time.Sleep(10 * time.Millisecond) {
+		if st := p.Stats()[0]; st.Alive {
+			if st.Probes == 0 {
+				t.Fatalf("got %+v", st)
+			}
+			return
+		}
+	}
+	t.Fatal("server is not revived by Probe")
+}
@@ -0,0 +1,286 @@
+package zradius
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// Server errors
+var (
+	ErrServerClosed  = errors.New("Server closed")
+	ErrUnknownClient = errors.New("Unknown client")
+	ErrNoHandler     = errors.New("No handler for packet code")
+)
+
+// Handler - Radius request handler, returns reply created by RadReply or nil if no reply must be sent
+type Handler interface {
+	ServeRadius(req *Packet) *Packet
+}
+
+// HandlerFunc - adapter to use ordinary function as Handler
+type HandlerFunc func(req *Packet) *Packet
+
+// ServeRadius - call f(req)
+func (f HandlerFunc) ServeRadius(req *Packet) *Packet {
+	return f(req)
+}
+
+// Accounting ports (RFC 2866), Status-Server reply depends on it if reply code is not set
+const (
+	AcctPort    = 1813
+	AcctPortOld = 1646
+)
+
+// StatusReply - Status-Server responder (RFC 5997): Accounting-Response on accounting port, Access-Accept
+// on other ports, reply has Message-Authenticator, request without Message-Authenticator is dropped
+func StatusReply(req *Packet) *Packet {
+	return statusReply(req, 0)
+}
+
+// statusReply - Status-Server reply with code, code is selected by local port if 0
+func statusReply(req *Packet, code byte) *Packet {
+	var ma bool
+
+	for _, a := range req.attr {
+		ma = ma || a.typ == zdict.AttrMsgAuth
+	}
+	if !ma {
+		return nil
+	}
+	if code == 0 {
+		code = zdict.AccessAccept
+		if req.conn != nil {
+			if la, ok := req.conn.LocalAddr().(*net.UDPAddr); ok && (la.Port == AcctPort || la.Port == AcctPortOld) {
+				code = zdict.AccountingResponse
+			}
+		}
+	}
+	rep := req.RadReply(code)
+	rep.AddMsgAuth()
+	return rep
+}
+
+// SecretFunc - shared secret lookup by client addr, nil secret means unknown client
+type SecretFunc func(addr *net.UDPAddr) []byte
+
+// Server - UDP Radius server with worker pool and per-code handlers
+type Server struct {
+	Secret     SecretFunc                   // shared secret lookup, used if Clients is not set
+	Clients    *Clients                     // clients registry, packets from unknown clients are dropped
+	Dups       *DupCache                    // duplicate requests cache, cached reply is sent for duplicate
+	Dict       *zdict.Dictionary            // dictionary for received packets, zdict.Default if nil
+	Workers    int                          // number of workers, runtime.NumCPU() if not set
+	StatusCode byte                         // Status-Server reply code, selected by local port if not set
+	ErrorLog   func(pkt *Packet, err error) // called for dropped packets, pkt may be nil
+	conn       *net.UDPConn                 // listening socket
+	mu         sync.RWMutex                 // handlers and closing lock
+	handlers   map[byte]Handler             // handlers by packet code
+	wg         sync.WaitGroup               // reader and workers, added under mu if not closing
+	closing    bool                         // Shutdown is called
+}
+
+// NewServer - create Server on conn with secret lookup function, secret may be nil if Clients is set
+func NewServer(conn *net.UDPConn, secret SecretFunc) *Server {
+	return &Server{
+		Secret:   secret,
+		conn:     conn,
+		handlers: make(map[byte]Handler),
+	}
+}
+
+// Handle - register handler for packet code, nil handler removes registration
+func (s *Server) Handle(code byte, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h == nil {
+		delete(s.handlers, code)
+		return
+	}
+	s.handlers[code] = h
+}
+
+// HandleFunc - register handler function for packet code
+func (s *Server) HandleFunc(code byte, f func(req *Packet) *Packet) {
+	s.Handle(code, HandlerFunc(f))
+}
+
+// handler - find handler for packet code
+func (s *Server) handler(code byte) Handler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.handlers[code]
+}
+
+// Serve - receive packets and pass them to workers until Shutdown or socket error,
+// returns ErrServerClosed after Shutdown
+func (s *Server) Serve() error {
+	n := s.Workers
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.wg.Add(n + 1) // Shutdown waits for reader and workers
+	s.mu.Unlock()
+	defer s.wg.Done()
+	queue := make(chan *Packet, n)
+	defer close(queue)
+	for i := 0; i < n; i++ {
+		go s.worker(queue)
+	}
+	for {
+		pkt, err := RadRecv(s.conn)
+		if s.isClosing() {
+			return ErrServerClosed
+		}
+		if err != nil {
+			if _, ok := err.(net.Error); ok { // socket error
+				return err
+			}
+			s.logError(nil, err) // bad packet len
+			continue
+		}
+		queue <- pkt
+	}
+}
+
+// Shutdown - stop receiving and wait for workers to finish queued packets, socket is closed on return,
+// returns ctx error if ctx is done before workers are finished
+func (s *Server) Shutdown(ctx context.Context) error {
+	var err error
+
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.closing = true
+	s.mu.Unlock()
+	s.conn.SetReadDeadline(time.Now()) // unblock reader
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	s.conn.Close()
+	return err
+}
+
+// isClosing - Shutdown is called
+func (s *Server) isClosing() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.closing
+}
+
+// worker - process packets from queue
+func (s *Server) worker(queue <-chan *Packet) {
+	defer s.wg.Done()
+	for pkt := range queue {
+		s.process(pkt)
+	}
+}
+
+// process - serve packet and log error, handler panic is logged and request is removed
+// from duplicates cache, so worker is not lost and retransmission is processed again
+func (s *Server) process(pkt *Packet) {
+	defer func() {
+		if r := recover(); r != nil {
+			if s.Dups != nil {
+				s.Dups.Forget(pkt)
+			}
+			s.logError(pkt, fmt.Errorf("Packet processing panic: %v", r))
+		}
+	}()
+	if err := s.serve(pkt); err != nil {
+		s.logError(pkt, err)
+	}
+}
+
+// serve - verify request, check duplicates, process request and send reply
+func (s *Server) serve(pkt *Packet) error {
+	var secret []byte
+
+	switch {
+	case s.Clients != nil:
+		if err := s.Clients.Check(pkt); err != nil {
+			return err
+		}
+		secret = pkt.secret
+	case s.Secret != nil:
+		secret = s.Secret(pkt.addr)
+	}
+	if secret == nil {
+		return ErrUnknownClient
+	}
+	pkt.secret = secret
+	pkt.dict = s.Dict
+	if err := pkt.Verify(secret); err != nil {
+		return err
+	}
+	if s.Dups != nil {
+		if data, dup := s.Dups.Check(pkt); dup {
+			if data == nil { // in progress or without reply
+				return nil
+			}
+			_, err := pkt.conn.WriteToUDP(data, pkt.addr)
+			return err
+		}
+	}
+	rep, err := s.handle(pkt)
+	if s.Dups != nil {
+		if err != nil {
+			s.Dups.Forget(pkt)
+		} else {
+			s.Dups.Store(pkt, rep)
+		}
+	}
+	if err != nil || rep == nil {
+		return err
+	}
+	return rep.Send()
+}
+
+// handle - decode request, call handler and encode reply
+func (s *Server) handle(pkt *Packet) (*Packet, error) {
+	if err := pkt.Decode(); err != nil {
+		return nil, err
+	}
+	h := s.handler(pkt.code)
+	if h == nil && pkt.code == zdict.StatusServer {
+		h = HandlerFunc(func(req *Packet) *Packet { return statusReply(req, s.StatusCode) })
+	}
+	if h == nil {
+		return nil, ErrNoHandler
+	}
+	rep := h.ServeRadius(pkt)
+	if rep == nil {
+		return nil, nil
+	}
+	if err := rep.Encode(false); err != nil {
+		return nil, fmt.Errorf("Reply encode error: %v", err)
+	}
+	return rep, nil
+}
+
+// logError - pass error to ErrorLog if set
+func (s *Server) logError(pkt *Packet, err error) {
+	if s.ErrorLog != nil {
+		s.ErrorLog(pkt, err)
+	}
+}
@@ -0,0 +1,240 @@
+package zradius
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// testServer - Server on local port with testSecret, Serve is running until test cleanup
+func testServer(t *testing.T) *Server {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(conn, func(*net.UDPAddr) []byte { return testSecret })
+	s.Workers = 2
+	return s
+}
+
+// testServe - run Serve, Shutdown and check Serve result on test cleanup
+func testServe(t *testing.T, s *Server) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- s.Serve() }()
+	t.Cleanup(func() {
+		if err := s.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-done; err != ErrServerClosed {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+}
+
+// testClient - RadClient for server with short retransmission timeouts
+func testClient(t *testing.T, addr net.Addr) *RadClient {
+	t.Helper()
+	c, err := NewRadClient(addr.String(), testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.IRT = 50 * time.Millisecond
+	c.MRT = 100 * time.Millisecond
+	c.MRD = 2 * time.Second
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// testErrorLog - collect ErrorLog errors
+type testErrorLog struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (l *testErrorLog) log(_ *Packet, err error) {
+	l.mu.Lock()
+	l.errs = append(l.errs, err)
+	l.mu.Unlock()
+}
+
+func (l *testErrorLog) has(s string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return hasError(l.errs, s)
+}
+
+// echoHandler - Access-Accept with User-Name from request
+func echoHandler(req *Packet) *Packet {
+	rep := req.RadReply(zdict.AccessAccept)
+	if a := req.GetAttr("User-Name"); a != nil {
+		rep.MustAddAttrStr("User-Name", string(a.GetData()))
+	}
+	return rep
+}
+
+func TestServer(t *testing.T) {
+	var el testErrorLog
+
+	s := testServer(t)
+	s.ErrorLog = el.log
+	s.HandleFunc(zdict.AccessRequest, echoHandler)
+	testServe(t, s)
+	c := testClient(t, s.conn.LocalAddr())
+	req := RadNew(zdict.AccessRequest)
+	req.MustAddAttrStr("User-Name", "bob")
+	req.MustAddAttrStr("User-Password", "secret")
+	rep, err := c.Exchange(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.GetCode() != zdict.AccessAccept || string(rep.GetAttr("User-Name").GetData()) != "bob" {
+		t.Fatalf("got %s", rep)
+	}
+	// default Status-Server responder
+	if rep, _, err = c.Status(context.Background()); err != nil || rep.GetCode() != zdict.AccessAccept {
+		t.Fatalf("Status-Server: %v", err)
+	}
+	// no handler
+	c.MRC = -1
+	c.MRD = 200 * time.Millisecond
+	if _, err = c.Exchange(context.Background(), RadNew(zdict.AccountingRequest)); err == nil {
+		t.Fatal("no error")
+	}
+	if !el.has(ErrNoHandler.Error()) {
+		t.Fatalf("ErrorLog: %v", el.errs)
+	}
+}
+
+func TestServerPanic(t *testing.T) {
+	var (
+		el     testErrorLog
+		mu     sync.Mutex
+		panics int
+	)
+
+	s := testServer(t)
+	s.Workers = 1
+	s.ErrorLog = el.log
+	s.Dups = NewDupCache(100, time.Minute)
+	s.HandleFunc(zdict.AccessRequest, func(req *Packet) *Packet {
+		mu.Lock()
+		p := panics == 0 && string(req.GetAttr("User-Name").GetData()) == "panic"
+		if p {
+			panics++
+		}
+		mu.Unlock()
+		if p {
+			panic("handler bug")
+		}
+		return echoHandler(req)
+	})
+	testServe(t, s)
+	c := testClient(t, s.conn.LocalAddr())
+	// first transmission panics, retransmission is not cached as duplicate and gets reply
+	req := RadNew(zdict.AccessRequest)
+	req.MustAddAttrStr("User-Name", "panic")
+	req.MustAddAttrStr("User-Password", "secret")
+	rep, err := c.Exchange(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	n := panics
+	mu.Unlock()
+	if rep.GetCode() != zdict.AccessAccept || n != 1 {
+		t.Fatalf("got %s, panics: %d", rep, n)
+	}
+	if !el.has("panic: handler bug") {
+		t.Fatalf("ErrorLog: %v", el.errs)
+	}
+	// Decode used to panic on Long-Extended fragment as first attr, single worker must be alive
+	conn, err := net.DialUDP("udp", nil, s.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err = conn.Write(testPacket(245, 6, 1, 0x80, 'a', 'b', 245, 5, 1, 0, 'c')); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, MaxPLen)
+	n, err = conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n < MinPLen || buf[0] != zdict.AccessAccept || buf[1] != 1 {
+		t.Fatalf("got %x", buf[:n])
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	s := testServer(t)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(); err != ErrServerClosed {
+		t.Fatalf("Serve after Shutdown: %v", err)
+	}
+	if err := s.Shutdown(context.Background()); err != ErrServerClosed {
+		t.Fatalf("second Shutdown: %v", err)
+	}
+	// Shutdown waits for request in progress
+	s = testServer(t)
+	started, finished := make(chan struct{}), make(chan struct{})
+	s.HandleFunc(zdict.AccessRequest, func(req *Packet) *Packet {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		close(finished)
+		return nil
+	})
+	done := make(chan error, 1)
+	go func() { done <- s.Serve() }()
+	c := testClient(t, s.conn.LocalAddr())
+	c.MRC = -1
+	go c.Exchange(context.Background(), RadNew(zdict.AccessRequest))
+	<-started
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Fatal("Shutdown returned before handler finished")
+	}
+	if err := <-done; !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Serve: %v", err)
+	}
+}
+
+func TestServerStatus(t *testing.T) {
+	for _, code := range []byte{0, zdict.AccessAccept, zdict.AccountingResponse} {
+		s := testServer(t)
+		s.StatusCode = code
+		testServe(t, s)
+		c := testClient(t, s.conn.LocalAddr())
+		rep, rtt, err := c.Status(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := code
+		if want == 0 { // test server is not on accounting port
+			want = zdict.AccessAccept
+		}
+		if rep.GetCode() != want || rep.GetAttr("Message-Authenticator") == nil || rtt <= 0 {
+			t.Fatalf("code %d: got %s, rtt %v", code, rep, rtt)
+		}
+	}
+	// Status-Server without Message-Authenticator is dropped
+	req := testDecode(t, testPacket())
+	req.code = zdict.StatusServer
+	if StatusReply(req) != nil {
+		t.Fatal("reply without Message-Authenticator")
+	}
+}
@@ -0,0 +1,360 @@
+package zradius
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// check data len for fixed size types
+func checkLen(dtyp int, data []byte, l int) error {
+	if len(data) != l {
+		return fmt.Errorf("Bad %s len: %d, must be %d", zdict.TypeName(dtyp), len(data), l)
+	}
+	return nil
+}
+
+// evalPrefix - evaluate ipv4prefix and ipv6prefix (RFC 8044)
+func evalPrefix(dtyp int, data []byte) (*net.IPNet, error) {
+	var (
+		bits int
+		ip   net.IP
+	)
+
+	if dtyp == zdict.TypeIP4Pfx {
+		if err := checkLen(dtyp, data, 6); err != nil {
+			return nil, err
+		}
+		ip = make(net.IP, net.IPv4len)
+	} else {
+		if len(data) < 2 || len(data) > 18 {
+			return nil, fmt.Errorf("Bad %s len: %d, must be 2..18", zdict.TypeName(dtyp), len(data))
+		}
+		ip = make(net.IP, net.IPv6len)
+	}
+	if bits = int(data[1]); bits > len(ip)*8 || (bits+7)/8 > len(data)-2 {
+		return nil, fmt.Errorf("Bad %s prefix len: %d", zdict.TypeName(dtyp), bits)
+	}
+	copy(ip, data[2:])
+	mask := net.CIDRMask(bits, len(ip)*8)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+}
+
+// evalData - evaluate raw data according to dictionary type
+func evalData(dtyp int, data []byte) (interface{}, error) {
+	var err error
+
+	switch dtyp {
+	case zdict.TypeString:
+		return string(data), nil
+	case zdict.TypeIP4:
+		if err = checkLen(dtyp, data, net.IPv4len); err != nil {
+			return nil, err
+		}
+		return net.IPv4(data[0], data[1], data[2], data[3]), nil
+	case zdict.TypeIP4Pfx, zdict.TypeIP6Pfx:
+		return evalPrefix(dtyp, data)
+	case zdict.TypeInt:
+		if err = checkLen(dtyp, data, 4); err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.Uint32(data), nil
+	case zdict.TypeInt64:
+		if err = checkLen(dtyp, data, 8); err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.Uint64(data), nil
+	case zdict.TypeDate:
+		if err = checkLen(dtyp, data, 4); err != nil {
+			return nil, err
+		}
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0), nil
+	case zdict.TypeIfID:
+		if err = checkLen(dtyp, data, 8); err != nil {
+			return nil, err
+		}
+		return net.HardwareAddr(append([]byte(nil), data...)), nil
+	case zdict.TypeIP6:
+		if err = checkLen(dtyp, data, net.IPv6len); err != nil {
+			return nil, err
+		}
+		return net.IP(append([]byte(nil), data...)), nil
+	case zdict.TypeByte:
+		if err = checkLen(dtyp, data, 1); err != nil {
+			return nil, err
+		}
+		return data[0], nil
+	case zdict.TypeEth:
+		if err = checkLen(dtyp, data, 6); err != nil {
+			return nil, err
+		}
+		return net.HardwareAddr(append([]byte(nil), data...)), nil
+	case zdict.TypeShort:
+		if err = checkLen(dtyp, data, 2); err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.Uint16(data), nil
+	case zdict.TypeSInt:
+		if err = checkLen(dtyp, data, 4); err != nil {
+			return nil, err
+		}
+		return int32(binary.BigEndian.Uint32(data)), nil
+	}
+	return data, nil // TypeRaw, TypeVSA
+}
+
+// encodePrefix - encode ipv4prefix and ipv6prefix (RFC 8044)
+func encodePrefix(dtyp int, val *net.IPNet) ([]byte, error) {
+	var (
+		ip   net.IP
+		l    int
+		data []byte
+	)
+
+	if val == nil {
+		return nil, fmt.Errorf("Prefix is nil")
+	}
+	ones, bits := val.Mask.Size()
+	if dtyp == zdict.TypeIP4Pfx {
+		if ip = val.IP.To4(); ip == nil || bits != 32 {
+			return nil, fmt.Errorf("Argument is not IPv4 prefix")
+		}
+		l = net.IPv4len
+	} else {
+		if ip = val.IP.To16(); ip == nil || val.IP.To4() != nil || bits != 128 {
+			return nil, fmt.Errorf("Argument is not IPv6 prefix")
+		}
+		l = (ones + 7) / 8 // only significant bytes of prefix
+	}
+	data = make([]byte, 2+l)
+	data[1] = byte(ones)
+	copy(data[2:], ip.Mask(val.Mask))
+	return data, nil
+}
+
+// encodeTime - encode date (unix time 32 bit)
+func encodeTime(val time.Time) ([]byte, error) {
+	var t [4]byte
+
+	u := val.Unix()
+	if u < 0 || u > 0xFFFFFFFF {
+		return nil, fmt.Errorf("Time out of range: %s", val)
+	}
+	binary.BigEndian.PutUint32(t[:], uint32(u))
+	return t[:], nil
+}
+
+// intValue - convert any integer or numeric string to sign and magnitude
+func intValue(v interface{}) (neg bool, mag uint64, err error) {
+	var i int64
+
+	switch t := v.(type) {
+	case int:
+		i = int64(t)
+	case int8:
+		i = int64(t)
+	case int16:
+		i = int64(t)
+	case int32:
+		i = int64(t)
+	case int64:
+		i = t
+	case uint:
+		return false, uint64(t), nil
+	case uint8:
+		return false, uint64(t), nil
+	case uint16:
+		return false, uint64(t), nil
+	case uint32:
+		return false, uint64(t), nil
+	case uint64:
+		return false, t, nil
+	case string:
+		if mag, err = strconv.ParseUint(t, 0, 64); err == nil {
+			return false, mag, nil
+		}
+		if i, err = strconv.ParseInt(t, 0, 64); err != nil {
+			return false, 0, fmt.Errorf("Bad integer value: %q", t)
+		}
+	default:
+		return false, 0, fmt.Errorf("Can't convert %T to integer", v)
+	}
+	if i < 0 {
+		return true, uint64(-(i + 1)) + 1, nil
+	}
+	return false, uint64(i), nil
+}
+
+// uintValue - convert value to unsigned integer with max value check
+func uintValue(v interface{}, max uint64) (uint64, error) {
+	neg, mag, err := intValue(v)
+	if err != nil {
+		return 0, err
+	}
+	if neg || mag > max {
+		return 0, fmt.Errorf("Integer value out of range: %v", v)
+	}
+	return mag, nil
+}
+
+// encodeInt - encode integer types
+func encodeInt(dtyp int, v interface{}) ([]byte, error) {
+	var (
+		u    uint64
+		err  error
+		data []byte
+	)
+
+	switch dtyp {
+	case zdict.TypeByte:
+		if u, err = uintValue(v, math.MaxUint8); err != nil {
+			return nil, err
+		}
+		return []byte{byte(u)}, nil
+	case zdict.TypeShort:
+		if u, err = uintValue(v, math.MaxUint16); err != nil {
+			return nil, err
+		}
+		data = make([]byte, 2)
+		binary.BigEndian.PutUint16(data, uint16(u))
+	case zdict.TypeInt:
+		if u, err = uintValue(v, math.MaxUint32); err != nil {
+			return nil, err
+		}
+		data = make([]byte, 4)
+		binary.BigEndian.PutUint32(data, uint32(u))
+	case zdict.TypeInt64:
+		if u, err = uintValue(v, math.MaxUint64); err != nil {
+			return nil, err
+		}
+		data = make([]byte, 8)
+		binary.BigEndian.PutUint64(data, u)
+	case zdict.TypeSInt:
+		neg, mag, err := intValue(v)
+		if err != nil {
+			return nil, err
+		}
+		if (neg && mag > -math.MinInt32) || (!neg && mag > math.MaxInt32) {
+			return nil, fmt.Errorf("Integer value out of range: %v", v)
+		}
+		i := int32(mag)
+		if neg {
+			i = int32(-int64(mag))
+		}
+		data = make([]byte, 4)
+		binary.BigEndian.PutUint32(data, uint32(i))
+	}
+	return data, nil
+}
+
+// encodeIP - encode ipaddr and ipv6addr from net.IP or string
+func encodeIP(dtyp int, v interface{}) ([]byte, error) {
+	var ip net.IP
+
+	switch t := v.(type) {
+	case net.IP:
+		ip = t
+	case string:
+		if ip = net.ParseIP(t); ip == nil {
+			return nil, fmt.Errorf("Bad IP address: %q", t)
+		}
+	default:
+		return nil, fmt.Errorf("Can't convert %T to IP address", v)
+	}
+	if dtyp == zdict.TypeIP4 {
+		if ip = ip.To4(); ip == nil {
+			return nil, fmt.Errorf("Argument is not IPv4")
+		}
+		return append([]byte(nil), ip...), nil
+	}
+	if ip.To4() != nil || ip.To16() == nil {
+		return nil, fmt.Errorf("Argument is not IPv6")
+	}
+	return append([]byte(nil), ip.To16()...), nil
+}
+
+// encodeHW - encode ifid and ether from net.HardwareAddr or string
+func encodeHW(dtyp int, v interface{}) ([]byte, error) {
+	var (
+		hw  net.HardwareAddr
+		err error
+	)
+
+	switch t := v.(type) {
+	case net.HardwareAddr:
+		hw = t
+	case string:
+		if hw, err = net.ParseMAC(t); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Can't convert %T to %s", v, zdict.TypeName(dtyp))
+	}
+	l := 6
+	if dtyp == zdict.TypeIfID {
+		l = 8
+	}
+	if len(hw) != l {
+		return nil, fmt.Errorf("Bad %s len: %d, must be %d", zdict.TypeName(dtyp), len(hw), l)
+	}
+	return append([]byte(nil), hw...), nil
+}
+
+// encodeValue - encode value according to dictionary type
+func encodeValue(d *zdict.Dictionary, ad *zdict.AttrData, v interface{}) ([]byte, error) {
+	if b, ok := v.([]byte); ok { // raw data for any type
+		return b, nil
+	}
+	switch ad.Dtyp {
+	case zdict.TypeString, zdict.TypeRaw:
+		if s, ok := v.(string); ok {
+			return []byte(s), nil
+		}
+		return nil, fmt.Errorf("Can't convert %T to %s", v, zdict.TypeName(ad.Dtyp))
+	case zdict.TypeIP4, zdict.TypeIP6:
+		return encodeIP(ad.Dtyp, v)
+	case zdict.TypeIP4Pfx, zdict.TypeIP6Pfx:
+		switch t := v.(type) {
+		case *net.IPNet:
+			return encodePrefix(ad.Dtyp, t)
+		case net.IPNet:
+			return encodePrefix(ad.Dtyp, &t)
+		case string:
+			_, n, err := net.ParseCIDR(t)
+			if err != nil {
+				return nil, err
+			}
+			return encodePrefix(ad.Dtyp, n)
+		}
+		return nil, fmt.Errorf("Can't convert %T to %s", v, zdict.TypeName(ad.Dtyp))
+	case zdict.TypeDate:
+		switch t := v.(type) {
+		case time.Time:
+			return encodeTime(t)
+		case string:
+			tm, err := time.Parse(time.RFC3339, t)
+			if err != nil {
+				return encodeInt(zdict.TypeInt, t) // unix time
+			}
+			return encodeTime(tm)
+		}
+		return encodeInt(zdict.TypeInt, v)
+	case zdict.TypeIfID, zdict.TypeEth:
+		return encodeHW(ad.Dtyp, v)
+	case zdict.TypeByte, zdict.TypeShort, zdict.TypeInt:
+		if n, ok := v.(string); ok { // named value
+			if vd := d.FindValueName(ad, n); vd != nil {
+				return encodeInt(ad.Dtyp, vd.Val)
+			}
+		}
+		return encodeInt(ad.Dtyp, v)
+	case zdict.TypeInt64, zdict.TypeSInt:
+		return encodeInt(ad.Dtyp, v)
+	}
+	return nil, fmt.Errorf("Can't convert %T to %s", v, zdict.TypeName(ad.Dtyp))
+}
@@ -0,0 +1,135 @@
+package zradius
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+func TestEvalData(t *testing.T) {
+	for _, c := range []struct {
+		dtyp int
+		data []byte
+		want string // fmt %v of value, empty for error
+	}{
+		{zdict.TypeString, []byte("bob"), "bob"},
+		{zdict.TypeRaw, []byte{1, 2}, "[1 2]"},
+		{zdict.TypeIP4, []byte{10, 0, 0, 1}, "10.0.0.1"},
+		{zdict.TypeIP4, []byte{10, 0, 0}, ""},
+		{zdict.TypeIP4Pfx, []byte{0, 24, 10, 1, 2, 3}, "10.1.2.0/24"},
+		{zdict.TypeIP4Pfx, []byte{0, 33, 10, 1, 2, 3}, ""},
+		{zdict.TypeIP6Pfx, []byte{0, 64, 0x20, 1, 0xd, 0xb8, 0, 0, 0, 1}, "2001:db8:0:1::/64"},
+		{zdict.TypeIP6Pfx, []byte{0, 64, 0x20, 1}, ""},
+		{zdict.TypeIP6, []byte{0x20, 1, 0xd, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, "2001:db8::1"},
+		{zdict.TypeIP6, []byte{0x20, 1}, ""},
+		{zdict.TypeInt, []byte{0, 0, 1, 0}, "256"},
+		{zdict.TypeInt, []byte{0, 0, 1}, ""},
+		{zdict.TypeInt64, []byte{0, 0, 0, 1, 0, 0, 0, 0}, "4294967296"},
+		{zdict.TypeDate, []byte{0x65, 0x53, 0xf1, 0x00}, time.Unix(0x6553f100, 0).String()},
+		{zdict.TypeIfID, []byte{0, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}, "00:11:22:33:44:55:66:77"},
+		{zdict.TypeEth, []byte{0, 0x11, 0x22, 0x33, 0x44, 0x55}, "00:11:22:33:44:55"},
+		{zdict.TypeEth, []byte{0, 0x11}, ""},
+		{zdict.TypeByte, []byte{7}, "7"},
+		{zdict.TypeShort, []byte{1, 0}, "256"},
+		{zdict.TypeSInt, []byte{0xff, 0xff, 0xff, 0xfe}, "-2"},
+	} {
+		v, err := evalData(c.dtyp, c.data)
+		if c.want == "" {
+			if err == nil {
+				t.Fatalf("%s %x: no error", zdict.TypeName(c.dtyp), c.data)
+			}
+			continue
+		}
+		if err != nil || fmt.Sprint(v) != c.want {
+			t.Fatalf("%s %x: got %v, %v, want %s", zdict.TypeName(c.dtyp), c.data, v, err, c.want)
+		}
+	}
+}
+
+func TestGetValue(t *testing.T) {
+	data := []byte{zdict.AccountingRequest, 1, 0, 0}
+	data = append(data, make([]byte, 16)...)
+	data = append(data, 5, 6, 0, 0, 0, 7) // NAS-Port
+	data = append(data, 55, 5, 1, 2, 3)   // Event-Timestamp with bad len
+	data[3] = byte(len(data))
+	pkt := testDecode(t, data)
+	if v, err := pkt.attr[0].GetValue(pkt); err != nil || v != uint32(7) {
+		t.Fatal(v, err)
+	}
+	if _, err := pkt.attr[1].GetValue(pkt); err == nil {
+		t.Fatal("bad date is evaluated")
+	}
+	if v, ok := pkt.attr[1].GetEData(pkt).([]byte); !ok || len(v) != 3 {
+		t.Fatal("raw data is not returned for bad value")
+	}
+}
+
+func TestAddAttr(t *testing.T) {
+	pkt := RadNew(zdict.AccountingRequest)
+	for _, c := range []struct {
+		name string
+		val  interface{}
+		want string // fmt %v of decoded value, empty if error is expected
+	}{
+		{"User-Name", "bob", "bob"},
+		{"User-Name", []byte("bob"), "bob"},
+		{"User-Name", 1, ""},
+		{"NAS-Port", 7, "7"},
+		{"NAS-Port", "10", "10"},
+		{"NAS-Port", uint16(11), "11"},
+		{"NAS-Port", -1, ""},
+		{"NAS-Port", uint64(1 << 33), ""},
+		{"NAS-IP-Address", "1.2.3.4", "1.2.3.4"},
+		{"NAS-IP-Address", net.IPv4(5, 6, 7, 8), "5.6.7.8"},
+		{"NAS-IP-Address", "::1", ""},
+		{"Framed-IPv6-Prefix", "2001:db8::/32", "2001:db8::/32"},
+		{"Framed-IPv6-Prefix", "10.0.0.0/8", ""},
+		{"Event-Timestamp", time.Unix(5, 0), time.Unix(5, 0).String()},
+		{"Event-Timestamp", "2020-01-01T00:00:00Z", time.Unix(1577836800, 0).String()},
+		{"Event-Timestamp", 100, time.Unix(100, 0).String()},
+		{"Framed-Interface-Id", "00:11:22:33:44:55:66:77", "00:11:22:33:44:55:66:77"},
+		{"Framed-Interface-Id", "00:11", ""},
+		{"Class", []byte{1, 2}, "[1 2]"},
+		{"Service-Type", "Framed-User", "2"},
+		{"Service-Type", "No-Such-Value", ""},
+		{"No-Such-Attr", 1, ""},
+	} {
+		err := pkt.AddAttr(c.name, c.val)
+		if c.want == "" {
+			if err == nil {
+				t.Fatalf("%s %v: no error", c.name, c.val)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s %v: %v", c.name, c.val, err)
+		}
+		a := pkt.attr[len(pkt.attr)-1]
+		if v, err := a.GetValue(pkt); err != nil || fmt.Sprint(v) != c.want {
+			t.Fatalf("%s %v: got %v, %v, want %s", c.name, c.val, v, err, c.want)
+		}
+	}
+	if err := pkt.Encode(true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncodeSInt(t *testing.T) {
+	for _, v := range []interface{}{int32(-5), -2147483648, "2147483647", uint8(3)} {
+		b, err := encodeInt(zdict.TypeSInt, v)
+		if err != nil {
+			t.Fatal(v, err)
+		}
+		if r, _ := evalData(zdict.TypeSInt, b); fmt.Sprint(r) != fmt.Sprint(v) {
+			t.Fatalf("got %v, want %v", r, v)
+		}
+	}
+	for _, v := range []interface{}{2147483648, -2147483649, "x", 1.5} {
+		if _, err := encodeInt(zdict.TypeSInt, v); err == nil {
+			t.Fatalf("%v: no error", v)
+		}
+	}
+}
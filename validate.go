@@ -0,0 +1,220 @@
+package zradius
+
+import (
+	"fmt"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// attr quantity in packet (RFC 2865 §5.44, RFC 2866 §5.13)
+const (
+	qtyAny  = iota // 0+, no limits
+	qtyNone        // 0, must not be present
+	qtyOpt         // 0-1
+	qtyOne         // 1, exactly one
+)
+
+// columns of quantity table
+var qtyCol = map[byte]int{
+	zdict.AccessRequest:      0,
+	zdict.AccessAccept:       1,
+	zdict.AccessReject:       2,
+	zdict.AccessChallenge:    3,
+	zdict.AccountingRequest:  4,
+	zdict.AccountingResponse: 5,
+}
+
+// quantity table: Access-Request, Access-Accept, Access-Reject, Access-Challenge, Accounting-Request, Accounting-Response
+var qtyTable = map[byte][6]int{
+	1:  {qtyOpt, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},   // User-Name
+	2:  {qtyOpt, qtyNone, qtyNone, qtyNone, qtyNone, qtyNone}, // User-Password
+	3:  {qtyOpt, qtyNone, qtyNone, qtyNone, qtyNone, qtyNone}, // CHAP-Password
+	4:  {qtyOpt, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone},  // NAS-IP-Address
+	5:  {qtyOpt, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone},  // NAS-Port
+	6:  {qtyOpt, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},   // Service-Type
+	7:  {qtyOpt, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},   // Framed-Protocol
+	8:  {qtyOpt, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},   // Framed-IP-Address
+	9:  {qtyOpt, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},   // Framed-IP-Netmask
+	10: {qtyNone, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},  // Framed-Routing
+	11: {qtyNone, qtyAny, qtyNone, qtyNone, qtyAny, qtyNone},  // Filter-Id
+	12: {qtyOpt, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},   // Framed-MTU
+	13: {qtyAny, qtyAny, qtyNone, qtyNone, qtyAny, qtyNone},   // Framed-Compression
+	14: {qtyAny, qtyAny, qtyNone, qtyNone, qtyAny, qtyNone},   // Login-IP-Host
+	15: {qtyNone, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},  // Login-Service
+	16: {qtyNone, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},  // Login-TCP-Port
+	18: {qtyNone, qtyAny, qtyAny, qtyAny, qtyNone, qtyNone},   // Reply-Message
+	19: {qtyOpt, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},   // Callback-Number
+	20: {qtyNone, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},  // Callback-Id
+	22: {qtyNone, qtyAny, qtyNone, qtyNone, qtyAny, qtyNone},  // Framed-Route
+	23: {qtyNone, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},  // Framed-IPX-Network
+	24: {qtyOpt, qtyOpt, qtyNone, qtyOpt, qtyOpt, qtyNone},    // State
+	25: {qtyNone, qtyAny, qtyNone, qtyNone, qtyAny, qtyNone},  // Class
+	26: {qtyAny, qtyAny, qtyNone, qtyAny, qtyAny, qtyAny},     // Vendor-Specific
+	27: {qtyNone, qtyOpt, qtyNone, qtyOpt, qtyOpt, qtyNone},   // Session-Timeout
+	28: {qtyNone, qtyOpt, qtyNone, qtyOpt, qtyOpt, qtyNone},   // Idle-Timeout
+	29: {qtyNone, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},  // Termination-Action
+	30: {qtyOpt, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone},  // Called-Station-Id
+	31: {qtyOpt, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone},  // Calling-Station-Id
+	32: {qtyOpt, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone},  // NAS-Identifier
+	33: {qtyAny, qtyAny, qtyAny, qtyAny, qtyAny, qtyAny},      // Proxy-State
+	34: {qtyOpt, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},   // Login-LAT-Service
+	35: {qtyOpt, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},   // Login-LAT-Node
+	36: {qtyOpt, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},   // Login-LAT-Group
+	37: {qtyNone, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},  // Framed-AppleTalk-Link
+	38: {qtyNone, qtyAny, qtyNone, qtyNone, qtyAny, qtyNone},  // Framed-AppleTalk-Network
+	39: {qtyNone, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},  // Framed-AppleTalk-Zone
+	40: {qtyNone, qtyNone, qtyNone, qtyNone, qtyOne, qtyNone}, // Acct-Status-Type
+	41: {qtyNone, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone}, // Acct-Delay-Time
+	42: {qtyNone, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone}, // Acct-Input-Octets
+	43: {qtyNone, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone}, // Acct-Output-Octets
+	44: {qtyNone, qtyNone, qtyNone, qtyNone, qtyOne, qtyNone}, // Acct-Session-Id
+	45: {qtyNone, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone}, // Acct-Authentic
+	46: {qtyNone, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone}, // Acct-Session-Time
+	47: {qtyNone, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone}, // Acct-Input-Packets
+	48: {qtyNone, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone}, // Acct-Output-Packets
+	49: {qtyNone, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone}, // Acct-Terminate-Cause
+	60: {qtyOpt, qtyNone, qtyNone, qtyNone, qtyNone, qtyNone}, // CHAP-Challenge
+	61: {qtyOpt, qtyNone, qtyNone, qtyNone, qtyOpt, qtyNone},  // NAS-Port-Type
+	62: {qtyOpt, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},   // Port-Limit
+	63: {qtyOpt, qtyOpt, qtyNone, qtyNone, qtyOpt, qtyNone},   // Login-LAT-Port
+}
+
+// knownCode - packet code is defined in RFC
+func knownCode(code byte) bool {
+	switch code {
+	case zdict.AccessRequest, zdict.AccessAccept, zdict.AccessReject, zdict.AccountingRequest,
+		zdict.AccountingResponse, zdict.AccountingStatus, zdict.PasswordRequest, zdict.PasswordAck,
+		zdict.PasswordReject, zdict.AccountingMessage, zdict.AccessChallenge, zdict.StatusServer,
+		zdict.StatusClient, zdict.DisconnectRequest, zdict.DisconnectACK, zdict.DisconnectNAK,
+		zdict.CoARequest, zdict.CoAACK, zdict.CoANAK:
+		return true
+	}
+	return false
+}
+
+// Validate - strict RFC check of decoded packet, returns list of violations, nil if packet is valid
+func (pkt *Packet) Validate() []error {
+	var errs []error
+
+	if !knownCode(pkt.code) {
+		errs = append(errs, fmt.Errorf("Unknown packet code %d", pkt.code))
+	}
+	errs = append(errs, pkt.validateLen()...)
+	for _, a := range pkt.attr {
+		if err := a.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return append(errs, pkt.validateQty()...)
+}
+
+// validateLen - check packet len rules (RFC 2865 §3)
+func (pkt *Packet) validateLen() []error {
+	var errs []error
+
+	if pkt.len < MinPLen || pkt.len > MaxPLen {
+		errs = append(errs, fmt.Errorf("Bad packet len: %d, must be %d..%d", pkt.len, MinPLen, MaxPLen))
+	}
+	if int(pkt.len) != len(pkt.data) {
+		errs = append(errs, fmt.Errorf("Packet len %d does not match data len %d", pkt.len, len(pkt.data)))
+	}
+	if pkt.rlen > int(pkt.len) {
+		errs = append(errs, fmt.Errorf("Trailing data after packet len %d, received: %d", pkt.len, pkt.rlen))
+	}
+	return errs
+}
+
+// validate - check attr data len according to dictionary type
+func (attr *Attr) validate() error {
+	if len(attr.data) == 0 {
+		return fmt.Errorf("Attr %s has no data", attr.name())
+	}
+	if attr.atyp == nil {
+		return nil
+	}
+	if attr.typ == zdict.AttrMsgAuth && len(attr.data) != 16 {
+		return fmt.Errorf("Attr %s bad len: %d, must be 16", attr.name(), len(attr.data))
+	}
+	if !attr.dcr { // data is encrypted, check encrypted len
+		switch attr.atyp.Enc {
+		case zdict.EncUsr:
+			if l := len(attr.data); l < 16 || l > usrMaxPass || l%16 != 0 {
+				return fmt.Errorf("Attr %s bad encrypted len: %d", attr.name(), l)
+			}
+			return nil
+		case zdict.EncTun:
+			if l := len(attr.data) - 1 - tunSaltLen; l < 16 || l%16 != 0 {
+				return fmt.Errorf("Attr %s bad encrypted len: %d", attr.name(), len(attr.data))
+			}
+			return nil
+		case zdict.EncAsc:
+			return nil
+		}
+	}
+	if _, err := evalData(attr.atyp.Dtyp, attr.data); err != nil {
+		return fmt.Errorf("Attr %s: %v", attr.name(), err)
+	}
+	return nil
+}
+
+// validateQty - check attr presence rules for packet code (RFC 2865 §5.44, RFC 2866 §5.13, RFC 3579, RFC 5997)
+func (pkt *Packet) validateQty() []error {
+	var (
+		errs []error
+		cnt  = make(map[byte]int)
+	)
+
+	for _, a := range pkt.attr {
+		cnt[a.typ]++
+	}
+	if col, ok := qtyCol[pkt.code]; ok {
+		for i := 1; i < 256; i++ { // in type order for stable list
+			typ := byte(i)
+			q, ok := qtyTable[typ]
+			if !ok {
+				continue
+			}
+			n := cnt[typ]
+			switch {
+			case q[col] == qtyNone && n > 0:
+				errs = append(errs, fmt.Errorf("Attr %s must not be present in packet code %d", qtyName(pkt, typ), pkt.code))
+			case q[col] == qtyOpt && n > 1:
+				errs = append(errs, fmt.Errorf("Attr %s must be present at most once, found %d", qtyName(pkt, typ), n))
+			case q[col] == qtyOne && n != 1:
+				errs = append(errs, fmt.Errorf("Attr %s must be present exactly once, found %d", qtyName(pkt, typ), n))
+			}
+		}
+	}
+	switch pkt.code {
+	case zdict.AccessRequest:
+		if cnt[2] > 0 && cnt[3] > 0 {
+			errs = append(errs, fmt.Errorf("User-Password and CHAP-Password must not be present together"))
+		}
+		if cnt[2] == 0 && cnt[3] == 0 && cnt[24] == 0 && cnt[79] == 0 {
+			errs = append(errs, fmt.Errorf("Access-Request must contain User-Password, CHAP-Password, State or EAP-Message"))
+		}
+		fallthrough
+	case zdict.AccountingRequest:
+		if cnt[4] == 0 && cnt[32] == 0 && cnt[95] == 0 {
+			errs = append(errs, fmt.Errorf("NAS-IP-Address, NAS-IPv6-Address or NAS-Identifier must be present"))
+		}
+	}
+	if cnt[79] > 0 && cnt[zdict.AttrMsgAuth] == 0 {
+		errs = append(errs, fmt.Errorf("EAP-Message requires Message-Authenticator"))
+	}
+	if pkt.code == zdict.StatusServer && cnt[zdict.AttrMsgAuth] == 0 {
+		errs = append(errs, fmt.Errorf("Status-Server requires Message-Authenticator"))
+	}
+	if cnt[zdict.AttrMsgAuth] > 1 {
+		errs = append(errs, fmt.Errorf("Message-Authenticator must be present at most once, found %d", cnt[zdict.AttrMsgAuth]))
+	}
+	return errs
+}
+
+// qtyName - attr name for quantity errors
+func qtyName(pkt *Packet, typ byte) string {
+	if ad := pkt.GetDict().FindAttrBin(typ); ad != nil {
+		return ad.Name
+	}
+	return fmt.Sprintf("%d", typ)
+}
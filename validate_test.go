@@ -0,0 +1,103 @@
+package zradius
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// raw attrs for validation tests
+var (
+	vUserName = []byte{1, 5, 'b', 'o', 'b'}
+	vUserPass = append([]byte{2, 18}, testBytes(16)...)
+	vCHAPPass = append([]byte{3, 19}, testBytes(17)...)
+	vNASIP    = []byte{4, 6, 10, 0, 0, 1}
+	vNASID    = []byte{32, 5, 'n', 'a', 's'}
+	vStatus   = []byte{40, 6, 0, 0, 0, 1}
+	vSession  = []byte{44, 4, 's', '1'}
+	vMsgAuth  = append([]byte{zdict.AttrMsgAuth, 18}, make([]byte, 16)...)
+)
+
+// joinAttrs - join raw attrs to new slice
+func joinAttrs(attrs ...[]byte) []byte {
+	var raw []byte
+	for _, a := range attrs {
+		raw = append(raw, a...)
+	}
+	return raw
+}
+
+// testValidate - decode raw packet with code and attrs and validate it
+func testValidate(t *testing.T, code byte, attrs ...[]byte) []error {
+	t.Helper()
+	data := testPacket(joinAttrs(attrs...)...)
+	data[0] = code
+	pkt := testDecode(t, data)
+	pkt.rlen = len(data)
+	return pkt.Validate()
+}
+
+// hasError - some error in list contains s
+func hasError(errs []error, s string) bool {
+	for _, err := range errs {
+		if strings.Contains(err.Error(), s) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateOK(t *testing.T) {
+	for name, errs := range map[string][]error{
+		"Access-Request":     testValidate(t, zdict.AccessRequest, vUserName, vUserPass, vNASIP),
+		"CHAP":               testValidate(t, zdict.AccessRequest, vUserName, vCHAPPass, vNASID),
+		"Accounting-Request": testValidate(t, zdict.AccountingRequest, vUserName, vNASIP, vStatus, vSession),
+		"Status-Server":      testValidate(t, zdict.StatusServer, vMsgAuth),
+	} {
+		if errs != nil {
+			t.Fatalf("%s: %v", name, errs)
+		}
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	for _, tc := range []struct {
+		code  byte
+		attrs [][]byte
+		want  string
+	}{
+		{255, [][]byte{vUserName}, "Unknown packet code 255"},
+		{zdict.AccessRequest, [][]byte{vUserPass, vCHAPPass, vNASIP}, "must not be present together"},
+		{zdict.AccessRequest, [][]byte{vUserName, vNASIP}, "must contain User-Password"},
+		{zdict.AccessRequest, [][]byte{vUserPass}, "NAS-Identifier must be present"},
+		{zdict.AccessRequest, [][]byte{vUserName, vUserName, vUserPass, vNASIP}, "User-Name must be present at most once"},
+		{zdict.AccessAccept, [][]byte{vUserPass}, "User-Password must not be present"},
+		{zdict.AccountingRequest, [][]byte{vNASIP, vStatus}, "Acct-Session-Id must be present exactly once"},
+		{zdict.AccessRequest, [][]byte{vUserPass, {4, 5, 10, 0, 0}}, "NAS-IP-Address"},
+		{zdict.AccessRequest, [][]byte{vUserPass, vNASIP, {1, 2}}, "User-Name has no data"},
+		{zdict.AccessRequest, [][]byte{{2, 17}, testBytes(15), vNASIP}, "bad encrypted len: 15"},
+		{zdict.AccessRequest, [][]byte{vUserPass, vNASIP, {zdict.AttrMsgAuth, 6, 0, 0, 0, 0}}, "must be 16"},
+		{zdict.StatusServer, nil, "Status-Server requires Message-Authenticator"},
+		{zdict.AccessRequest, [][]byte{vUserPass, vNASIP, vMsgAuth, vMsgAuth}, "Message-Authenticator must be present at most once"},
+	} {
+		errs := testValidate(t, tc.code, tc.attrs...)
+		if !hasError(errs, tc.want) {
+			t.Fatalf("code %d %x: %q not found in %v", tc.code, tc.attrs, tc.want, errs)
+		}
+	}
+}
+
+func TestValidateLen(t *testing.T) {
+	pkt := testDecode(t, testPacket(joinAttrs(vUserPass, vNASIP)...))
+	pkt.rlen = len(pkt.data) + 3 // trailing garbage is cut by RadRecv
+	errs := pkt.Validate()
+	if len(errs) != 1 || !hasError(errs, "Trailing data") {
+		t.Fatalf("got %v", errs)
+	}
+	pkt.rlen = len(pkt.data)
+	pkt.len++
+	if errs = pkt.Validate(); !hasError(errs, "does not match data len") {
+		t.Fatalf("got %v", errs)
+	}
+}
@@ -0,0 +1,156 @@
+package zradius
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// Verification errors
+var (
+	ErrPktLen   = errors.New("Packet too short for verification")
+	ErrReqAuth  = errors.New("Request authenticator mismatch")
+	ErrRespAuth = errors.New("Response authenticator mismatch")
+	ErrRespID   = errors.New("Response ID does not match request")
+	ErrNoReq    = errors.New("Request for response is not set")
+	ErrMsgAuth  = errors.New("Message-Authenticator mismatch")
+	ErrNoMsgA   = errors.New("Message-Authenticator is missing")
+	ErrMsgALen  = errors.New("Message-Authenticator has wrong length")
+)
+
+// isReply - packet code is a response code
+func isReply(code byte) bool {
+	switch code {
+	case zdict.AccessAccept, zdict.AccessReject, zdict.AccessChallenge, zdict.AccountingResponse,
+		zdict.DisconnectACK, zdict.DisconnectNAK, zdict.CoAACK, zdict.CoANAK:
+		return true
+	}
+	return false
+}
+
+// calcAuth - MD5(Code+ID+Length+auth+Attributes+secret) over raw packet data
+func (pkt *Packet) calcAuth(auth, secret []byte) []byte {
+	hmd := md5.New()
+	hmd.Write(pkt.data[:4])
+	hmd.Write(auth)
+	hmd.Write(pkt.data[MinPLen:])
+	hmd.Write(secret)
+	return hmd.Sum(nil)
+}
+
+// calcMsgAuth - HMAC-MD5 over packet data with zeroed Message-Authenticator value
+func calcMsgAuth(data, secret []byte) []byte {
+	hm := hmac.New(md5.New, secret)
+	hm.Write(data)
+	return hm.Sum(nil)
+}
+
+// findMsgAuth - return Message-Authenticator position in raw packet data, -1 if not found
+func findMsgAuth(data []byte) (int, error) {
+	var al int
+
+	for bp := MinPLen; bp+2 <= len(data); bp += al {
+		if al = int(data[bp+1]); al < 2 {
+			return -1, nil // broken packet, Decode will fail on it
+		}
+		if data[bp] != zdict.AttrMsgAuth {
+			continue
+		}
+		if al != 18 || bp+al > len(data) {
+			return -1, ErrMsgALen
+		}
+		return bp, nil
+	}
+	return -1, nil
+}
+
+// verifyMsgAuth - check Message-Authenticator, auth - authenticator used for calculation
+func (pkt *Packet) verifyMsgAuth(auth, secret []byte, require bool) error {
+	var (
+		pos int
+		err error
+		buf []byte
+	)
+
+	if pos, err = findMsgAuth(pkt.data); err != nil {
+		return err
+	}
+	if pos < 0 {
+		if require {
+			return ErrNoMsgA
+		}
+		return nil
+	}
+	buf = getPBuf()
+	defer putPBuf(buf)
+	buf = buf[:copy(buf, pkt.data)]
+	copy(buf[4:MinPLen], auth)
+	copy(buf[pos+2:pos+18], zeroAuth[:])
+	if !hmac.Equal(calcMsgAuth(buf, secret), pkt.data[pos+2:pos+18]) {
+		return ErrMsgAuth
+	}
+	return nil
+}
+
+// VerifyMsgAuth - verify Message-Authenticator (RFC 3579), require - fail if attr is missing,
+// responses are verified against request set by RadReply or SetRequest
+func (pkt *Packet) VerifyMsgAuth(secret []byte, require bool) error {
+	if len(pkt.data) < MinPLen {
+		return ErrPktLen
+	}
+	code := pkt.data[0]
+	switch {
+	case isReply(code):
+		if pkt.req == nil {
+			return ErrNoReq
+		}
+		return pkt.verifyMsgAuth(pkt.req.auth[:], secret, require)
+	case isHashAuth(code):
+		return pkt.verifyMsgAuth(zeroAuth[:], secret, require)
+	}
+	return pkt.verifyMsgAuth(pkt.data[4:MinPLen], secret, require)
+}
+
+// Verify - verify authenticator and Message-Authenticator (if present) of received packet,
+// responses are verified against request set by RadReply or SetRequest
+func (pkt *Packet) Verify(secret []byte) error {
+	if len(pkt.data) < MinPLen {
+		return ErrPktLen
+	}
+	code := pkt.data[0]
+	if isReply(code) {
+		if pkt.req == nil {
+			return ErrNoReq
+		}
+		return pkt.VerifyReply(pkt.req, secret)
+	}
+	if !isHashAuth(code) { // Access-Request and Status-Server have random authenticator
+		return pkt.verifyMsgAuth(pkt.data[4:MinPLen], secret, false)
+	}
+	if subtle.ConstantTimeCompare(pkt.calcAuth(zeroAuth[:], secret), pkt.data[4:MinPLen]) != 1 {
+		return ErrReqAuth
+	}
+	return pkt.verifyMsgAuth(zeroAuth[:], secret, false)
+}
+
+// VerifyReply - verify Response Authenticator of received reply against request,
+// on success request is set for reply
+func (pkt *Packet) VerifyReply(req *Packet, secret []byte) error {
+	if len(pkt.data) < MinPLen {
+		return ErrPktLen
+	}
+	if pkt.data[1] != req.id {
+		return ErrRespID
+	}
+	if subtle.ConstantTimeCompare(pkt.calcAuth(req.auth[:], secret), pkt.data[4:MinPLen]) != 1 {
+		return ErrRespAuth
+	}
+	if err := pkt.verifyMsgAuth(req.auth[:], secret, false); err != nil {
+		return err
+	}
+	pkt.req = req
+	return nil
+}
@@ -0,0 +1,142 @@
+package zradius
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/andrewz1/zradius/zdict"
+)
+
+// replyCode - positive reply code for request code
+func replyCode(code byte) byte {
+	if code == zdict.AccessRequest || code == zdict.StatusServer {
+		return zdict.AccessAccept
+	}
+	return code + 1
+}
+
+func TestVerify(t *testing.T) {
+	for _, code := range []byte{zdict.AccessRequest, zdict.AccountingRequest, zdict.DisconnectRequest, zdict.CoARequest} {
+		req := RadNew(code)
+		req.SetSecret(testSecret)
+		req.MustAddAttrStr("User-Name", "bob")
+		if err := req.Encode(true); err != nil {
+			t.Fatal(err)
+		}
+		got := testDecode(t, req.data)
+		if err := got.Verify(testSecret); err != nil {
+			t.Fatalf("code %d: %v", code, err)
+		}
+		if err := got.Verify([]byte("bad")); code != zdict.AccessRequest && err != ErrReqAuth {
+			t.Fatalf("code %d: %v", code, err)
+		}
+		rep := got.RadReply(replyCode(code))
+		rep.MustAddAttrStr("Reply-Message", "hi")
+		if err := rep.Encode(false); err != nil {
+			t.Fatal(err)
+		}
+		r := testDecode(t, rep.data)
+		if err := r.Verify(testSecret); err != ErrNoReq {
+			t.Fatalf("code %d: %v", code, err)
+		}
+		if err := r.VerifyReply(req, []byte("bad")); err != ErrRespAuth {
+			t.Fatalf("code %d: %v", code, err)
+		}
+		if err := r.VerifyReply(req, testSecret); err != nil {
+			t.Fatalf("code %d: %v", code, err)
+		}
+		if r.GetRequest() != req || r.Verify(testSecret) != nil {
+			t.Fatalf("code %d: request is not set on verify", code)
+		}
+		r.data[1]++
+		if err := r.VerifyReply(req, testSecret); err != ErrRespID {
+			t.Fatalf("code %d: %v", code, err)
+		}
+	}
+	if err := (&Packet{data: make([]byte, 10)}).Verify(testSecret); err != ErrPktLen {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	req := RadNew(zdict.AccountingRequest)
+	req.SetSecret(testSecret)
+	req.MustAddAttrStr("User-Name", "bob")
+	if err := req.Encode(true); err != nil {
+		t.Fatal(err)
+	}
+	got := testDecode(t, append([]byte(nil), req.data...))
+	got.data[len(got.data)-1] ^= 1
+	if err := got.Verify(testSecret); err != ErrReqAuth {
+		t.Fatal(err)
+	}
+}
+
+func TestMsgAuthKnownAnswer(t *testing.T) {
+	data := mustHex("0100002b0f403f9473978057bd83d5cb98f4227a0105626f62501200000000000000000000000000000000")
+	ma := mustHex("f0ddce7ccccd68abb205aefe19c66925")
+	if got := calcMsgAuth(data, testSecret); !bytes.Equal(got, ma) {
+		t.Fatalf("got %x", got)
+	}
+	copy(data[len(data)-16:], ma)
+	pkt := testDecode(t, data)
+	if err := pkt.VerifyMsgAuth(testSecret, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := pkt.Verify(testSecret); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMsgAuth(t *testing.T) {
+	for _, code := range []byte{zdict.AccessRequest, zdict.AccountingRequest, zdict.StatusServer, zdict.CoARequest} {
+		req := RadNew(code)
+		req.SetSecret(testSecret)
+		req.MustAddAttrStr("User-Name", "bob")
+		req.AddMsgAuth()
+		req.AddMsgAuth() // added once
+		if err := req.Encode(true); err != nil {
+			t.Fatal(err)
+		}
+		if req.data[MinPLen] != zdict.AttrMsgAuth || req.Count("Message-Authenticator") != 1 {
+			t.Fatalf("code %d: Message-Authenticator is not first", code)
+		}
+		got := testDecode(t, req.data)
+		if err := got.Verify(testSecret); err != nil {
+			t.Fatalf("code %d: %v", code, err)
+		}
+		if err := got.VerifyMsgAuth([]byte("bad"), true); err != ErrMsgAuth {
+			t.Fatalf("code %d: %v", code, err)
+		}
+		rep := got.RadReply(replyCode(code))
+		rep.AddMsgAuth()
+		if err := rep.Encode(false); err != nil {
+			t.Fatal(err)
+		}
+		r := testDecode(t, rep.data)
+		if err := r.VerifyReply(req, testSecret); err != nil {
+			t.Fatalf("code %d: %v", code, err)
+		}
+		if err := r.VerifyMsgAuth(testSecret, true); err != nil {
+			t.Fatalf("code %d: %v", code, err)
+		}
+		r.data[MinPLen+2] ^= 1
+		if err := r.VerifyMsgAuth(testSecret, true); err != ErrMsgAuth {
+			t.Fatalf("code %d: %v", code, err)
+		}
+	}
+	pkt := RadNew(zdict.AccessRequest)
+	pkt.MustAddAttrStr("User-Name", "bob")
+	pkt.Encode(true)
+	if err := pkt.VerifyMsgAuth(testSecret, true); err != ErrNoMsgA {
+		t.Fatal(err)
+	}
+	if err := pkt.VerifyMsgAuth(testSecret, false); err != nil {
+		t.Fatal(err)
+	}
+	bad := append(append([]byte(nil), pkt.data...), zdict.AttrMsgAuth, 4, 0, 0)
+	bad[3] += 4
+	if err := (&Packet{data: bad}).VerifyMsgAuth(testSecret, false); err != ErrMsgALen {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,542 @@
+package zdict
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// max depth of $INCLUDE
+const maxIncDepth = 32
+
+// type aliases from FreeRADIUS dictionary
+var typeAliases = map[string]int{
+	"abinary": TypeRaw,
+	"uint8":   TypeByte,
+	"uint16":  TypeShort,
+	"uint32":  TypeInt,
+	"uint64":  TypeInt64,
+	"int32":   TypeSInt,
+	// containers, stored as raw data
+	"combo-ip":      TypeRaw, // IPv4 or IPv6 addr
+	"tlv":           TypeRaw,
+	"extended":      TypeRaw,
+	"long-extended": TypeRaw,
+	"evs":           TypeRaw,
+}
+
+// BEGIN-VENDOR block
+type vendBlock struct {
+	vend *VendorData // vendor of block
+	evs  byte        // attr type for Extended-Vendor-Specific block, 0 for VSA
+}
+
+// loader - dictionary files parser, entries are added to dictionary only if all files are parsed
+type loader struct {
+	d       *Dictionary
+	attrs   []*AttrData
+	vendors []*VendorData
+	values  []loadValue
+	vals    map[valNameKey]*ValueData
+	aname   map[string]*AttrData
+	abin    map[binKey]*AttrData
+	vname   map[string]*VendorData
+	vid     map[uint32]*VendorData
+	skipped map[string]bool // skipped attrs, their values are skipped too
+	depth   int
+	pos     string // current file and line for warnings
+}
+
+// parsed named value
+type loadValue struct {
+	akey binKey
+	val  *ValueData
+}
+
+func newLoader(d *Dictionary) *loader {
+	return &loader{
+		d:       d,
+		aname:   make(map[string]*AttrData),
+		abin:    make(map[binKey]*AttrData),
+		vname:   make(map[string]*VendorData),
+		vid:     make(map[uint32]*VendorData),
+		vals:    make(map[valNameKey]*ValueData),
+		skipped: make(map[string]bool),
+	}
+}
+
+// warn - pass warning with current position to WarnLog
+func (l *loader) warn(format string, args ...interface{}) {
+	if l.d.WarnLog != nil {
+		l.d.WarnLog(l.pos + ": " + fmt.Sprintf(format, args...))
+	}
+}
+
+// LoadFile - load FreeRADIUS format dictionary file into default dictionary
+func LoadFile(path string) error {
+	return Default.LoadFile(path)
+}
+
+// LoadDir - load all files from directory as FreeRADIUS format dictionaries into default dictionary
+func LoadDir(dir string) error {
+	return Default.LoadDir(dir)
+}
+
+// LoadFile - load FreeRADIUS format dictionary file
+func (d *Dictionary) LoadFile(path string) error {
+	d.loadMu.Lock()
+	defer d.loadMu.Unlock()
+	l := newLoader(d)
+	if err := l.parseFile(path); err != nil {
+		return err
+	}
+	l.commit()
+	return nil
+}
+
+// LoadDir - load all files from directory as FreeRADIUS format dictionaries
+func (d *Dictionary) LoadDir(dir string) error {
+	d.loadMu.Lock()
+	defer d.loadMu.Unlock()
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	l := newLoader(d)
+	for _, e := range ents {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if err = l.parseFile(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	l.commit()
+	return nil
+}
+
+// add parsed entries to dictionary
+func (l *loader) commit() {
+	for _, v := range l.vendors {
+		l.d.addVendorData(v)
+	}
+	for _, a := range l.attrs {
+		l.d.addAttrData(a)
+	}
+	for _, v := range l.values {
+		l.d.addValueData(v.akey, v.val)
+	}
+}
+
+// parse one dictionary file
+func (l *loader) parseFile(path string) error {
+	var (
+		f    *os.File
+		err  error
+		blk  vendBlock // current BEGIN-VENDOR block
+		line int
+	)
+
+	if l.depth >= maxIncDepth {
+		return fmt.Errorf("%s: Too many nested includes", path)
+	}
+	if f, err = os.Open(path); err != nil {
+		return err
+	}
+	defer f.Close()
+	l.depth++
+	defer func() {
+		l.depth--
+	}()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line++
+		l.pos = path + ":" + strconv.Itoa(line)
+		if err = l.parseLine(path, sc.Text(), &blk); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+	}
+	if err = sc.Err(); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if blk.vend != nil {
+		return fmt.Errorf("%s: Missing END-VENDOR %s", path, blk.vend.Name)
+	}
+	return nil
+}
+
+// parse one dictionary line
+func (l *loader) parseLine(path, s string, blk *vendBlock) error {
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		s = s[:i]
+	}
+	f := strings.Fields(s)
+	if len(f) == 0 {
+		return nil
+	}
+	switch f[0] {
+	case "ATTRIBUTE":
+		return l.parseAttr(f[1:], *blk)
+	case "VALUE":
+		return l.parseValue(f[1:])
+	case "VENDOR":
+		return l.parseVendor(f[1:])
+	case "BEGIN-VENDOR":
+		if len(f) < 2 {
+			return fmt.Errorf("BEGIN-VENDOR needs vendor name")
+		}
+		if blk.vend != nil {
+			return fmt.Errorf("BEGIN-VENDOR %s inside of %s block", f[1], blk.vend.Name)
+		}
+		if blk.vend = l.findVendor(f[1]); blk.vend == nil {
+			return fmt.Errorf("Unknown vendor %s", f[1])
+		}
+		blk.evs = 0
+		if len(f) > 2 {
+			n, err := strconv.Atoi(strings.TrimPrefix(f[2], "format=Extended-Vendor-Specific-"))
+			if err != nil || n < 1 || n > AttrLongExt2-AttrExt1+1 {
+				blk.vend = nil
+				return fmt.Errorf("Unsupported BEGIN-VENDOR format %s", f[2])
+			}
+			blk.evs = byte(AttrExt1 + n - 1)
+		}
+	case "END-VENDOR":
+		if len(f) < 2 {
+			return fmt.Errorf("END-VENDOR needs vendor name")
+		}
+		if blk.vend == nil || !strings.EqualFold(blk.vend.Name, f[1]) {
+			return fmt.Errorf("END-VENDOR %s without BEGIN-VENDOR", f[1])
+		}
+		blk.vend = nil
+	case "$INCLUDE", "$INCLUDE-":
+		if len(f) < 2 {
+			return fmt.Errorf("%s needs file name", f[0])
+		}
+		inc := f[1]
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		if f[0] == "$INCLUDE-" { // optional include
+			if _, err := os.Stat(inc); os.IsNotExist(err) {
+				return nil
+			}
+		}
+		return l.parseFile(inc)
+	default:
+		return fmt.Errorf("Unknown keyword %s", f[0])
+	}
+	return nil
+}
+
+// parse number in decimal or hex
+func parseNum(s string, bits int) (uint64, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return strconv.ParseUint(s[2:], 16, bits)
+	}
+	return strconv.ParseUint(s, 10, bits)
+}
+
+// find data type by name
+func parseType(s string) (int, error) {
+	if i := strings.IndexByte(s, '['); i > 0 { // octets[16]
+		s = s[:i]
+	}
+	s = strings.ToLower(s)
+	for i, n := range typeNames {
+		if n == s {
+			return i, nil
+		}
+	}
+	if t, ok := typeAliases[s]; ok {
+		return t, nil
+	}
+	return 0, fmt.Errorf("Unknown data type %s", s)
+}
+
+// find vendor in parsed or loaded entries
+func (l *loader) findVendor(name string) *VendorData {
+	if v, ok := l.vname[strings.ToLower(name)]; ok {
+		return v
+	}
+	return l.d.FindVendorName(name)
+}
+
+// parse VENDOR line
+func (l *loader) parseVendor(f []string) error {
+	var (
+		vid uint64
+		err error
+	)
+
+	if len(f) < 2 {
+		return fmt.Errorf("VENDOR needs name and number")
+	}
+	if vid, err = parseNum(f[1], 32); err != nil {
+		return fmt.Errorf("Bad vendor number %s", f[1])
+	}
+	vd := &VendorData{
+		Name:    f[0],
+		ID:      uint32(vid),
+		TypeLen: 1,
+		LenLen:  1,
+	}
+	if len(f) > 2 {
+		if err = parseVendorFormat(vd, f[2]); err != nil {
+			return err
+		}
+	}
+	if old := l.findVendor(vd.Name); old != nil {
+		if sameVendor(old, vd) {
+			return nil
+		}
+		return fmt.Errorf("Vendor %s already defined with different parameters", vd.Name)
+	}
+	old, ok := l.vid[vd.ID]
+	if !ok {
+		old = l.d.FindVendorID(vd.ID)
+	}
+	if old != nil {
+		return fmt.Errorf("Vendor %s conflicts with %s, number %d", vd.Name, old.Name, vd.ID)
+	}
+	l.vendors = append(l.vendors, vd)
+	l.vname[strings.ToLower(vd.Name)] = vd
+	l.vid[vd.ID] = vd
+	return nil
+}
+
+// parse vendor format: format=t,l[,c]
+func parseVendorFormat(vd *VendorData, s string) error {
+	if !strings.HasPrefix(s, "format=") {
+		return fmt.Errorf("Unknown vendor flag %s", s)
+	}
+	p := strings.Split(s[len("format="):], ",")
+	if len(p) != 2 && len(p) != 3 {
+		return fmt.Errorf("Bad vendor format %s", s)
+	}
+	switch p[0] {
+	case "1", "2", "4":
+		vd.TypeLen = int(p[0][0] - '0')
+	default:
+		return fmt.Errorf("Bad vendor type len in %s", s)
+	}
+	switch p[1] {
+	case "0", "1", "2":
+		vd.LenLen = int(p[1][0] - '0')
+	default:
+		return fmt.Errorf("Bad vendor len len in %s", s)
+	}
+	if len(p) == 3 {
+		if p[2] != "c" || vd.TypeLen != 1 || vd.LenLen != 1 {
+			return fmt.Errorf("Bad vendor continuation in %s", s)
+		}
+		vd.Cont = true
+	}
+	return nil
+}
+
+// compare vendors ignoring name case
+func sameVendor(a, b *VendorData) bool {
+	return strings.EqualFold(a.Name, b.Name) && a.ID == b.ID &&
+		a.TypeLen == b.TypeLen && a.LenLen == b.LenLen && a.Cont == b.Cont
+}
+
+// parse attr number: type, type.ext or type.26.vid.vtyp
+func parseAttrNum(ad *AttrData, s string) error {
+	var (
+		n   [4]uint64
+		err error
+	)
+
+	p := strings.Split(s, ".")
+	if len(p) != 1 && len(p) != 2 && len(p) != 4 {
+		return fmt.Errorf("Bad attribute number %s", s)
+	}
+	for i := range p {
+		bits := 8
+		if i == 2 { // VendorID
+			bits = 32
+		}
+		if n[i], err = parseNum(p[i], bits); err != nil {
+			return fmt.Errorf("Bad attribute number %s", s)
+		}
+	}
+	ad.Typ = byte(n[0])
+	if len(p) == 1 {
+		return nil
+	}
+	if !IsExt(ad.Typ) {
+		return fmt.Errorf("Attribute %d is not extended", ad.Typ)
+	}
+	ad.Ext = byte(n[1])
+	if len(p) == 2 {
+		return nil
+	}
+	if ad.Ext != ExtEVS {
+		return fmt.Errorf("Extended-Type %d is not Extended-Vendor-Specific", ad.Ext)
+	}
+	ad.Vid = uint32(n[2])
+	ad.setVtyp(uint32(n[3]))
+	return nil
+}
+
+// parse ATTRIBUTE line
+func (l *loader) parseAttr(f []string, blk vendBlock) error {
+	var (
+		dtyp int
+		vt   uint64
+		err  error
+	)
+
+	if len(f) < 3 {
+		return fmt.Errorf("ATTRIBUTE needs name, number and type")
+	}
+	if blk.vend != nil && blk.evs == 0 && strings.IndexByte(f[1], '.') >= 0 { // TLV inside of VSA (WiMAX)
+		l.skipped[strings.ToLower(f[0])] = true
+		l.warn("TLV attribute %s %s of vendor %s is not supported, skipped", f[0], f[1], blk.vend.Name)
+		return nil
+	}
+	if dtyp, err = parseType(f[2]); err != nil {
+		return err
+	}
+	ad := &AttrData{
+		Name: f[0],
+		Dtyp: dtyp,
+	}
+	if len(f) > 3 {
+		if v := l.findVendor(f[3]); v != nil { // old style vendor attr
+			blk = vendBlock{vend: v}
+		} else if err = parseFlags(ad, f[3]); err != nil {
+			return err
+		}
+	}
+	if blk.vend != nil && blk.evs == 0 { // VSA, VendorType len is set by vendor format
+		if vt, err = parseNum(f[1], 8*blk.vend.TypeLen); err != nil {
+			return fmt.Errorf("Bad vendor attribute number %s", f[1])
+		}
+		ad.Typ = AttrVSA
+		ad.Vid = blk.vend.ID
+		ad.setVtyp(uint32(vt))
+		return l.addAttr(ad)
+	}
+	if err = parseAttrNum(ad, f[1]); err != nil {
+		return err
+	}
+	if blk.vend != nil {
+		if ad.Ext != 0 {
+			return fmt.Errorf("Extended attribute %s inside of vendor block", f[1])
+		}
+		ad.Vid = blk.vend.ID
+		ad.setVtyp(uint32(ad.Typ))
+		ad.Typ = blk.evs
+		ad.Ext = ExtEVS
+	}
+	return l.addAttr(ad)
+}
+
+// parse VALUE line
+func (l *loader) parseValue(f []string) error {
+	var (
+		num uint64
+		err error
+	)
+
+	if len(f) < 3 {
+		return fmt.Errorf("VALUE needs attribute, name and number")
+	}
+	ad, ok := l.aname[strings.ToLower(f[0])]
+	if !ok {
+		ad = l.d.FindAttrName(f[0])
+	}
+	if ad == nil && l.skipped[strings.ToLower(f[0])] {
+		return nil
+	}
+	if ad == nil {
+		return fmt.Errorf("Unknown attribute %s", f[0])
+	}
+	switch ad.Dtyp {
+	case TypeInt, TypeByte, TypeShort:
+	default:
+		return fmt.Errorf("Attribute %s of type %s can't have named values", ad.Name, TypeName(ad.Dtyp))
+	}
+	if num, err = parseNum(f[2], 32); err != nil {
+		return fmt.Errorf("Bad value number %s", f[2])
+	}
+	akey := ad.key()
+	vk := valNameKey{akey, strings.ToLower(f[1])}
+	old, ok := l.vals[vk]
+	if !ok {
+		old = l.d.FindValueName(ad, f[1])
+	}
+	if old != nil {
+		if old.Val == uint32(num) {
+			return nil
+		}
+		return fmt.Errorf("Value %s of %s already defined as %d", f[1], ad.Name, old.Val)
+	}
+	vd := &ValueData{
+		Name: f[1],
+		Val:  uint32(num),
+	}
+	l.values = append(l.values, loadValue{akey, vd})
+	l.vals[vk] = vd
+	return nil
+}
+
+// parse ATTRIBUTE flags
+func parseFlags(ad *AttrData, s string) error {
+	for _, fl := range strings.Split(s, ",") {
+		switch {
+		case fl == "has_tag":
+			ad.Tag = true
+		case fl == "concat":
+			ad.Concat = true
+		case strings.HasPrefix(fl, "encrypt="):
+			switch fl[len("encrypt="):] {
+			case "1":
+				ad.Enc = EncUsr
+			case "2":
+				ad.Enc = EncTun
+			case "3":
+				ad.Enc = EncAsc
+			default:
+				return fmt.Errorf("Unknown encryption %s", fl)
+			}
+		}
+		// other flags are ignored
+	}
+	return nil
+}
+
+// compare attrs ignoring name case
+func sameAttr(a, b *AttrData) bool {
+	return strings.EqualFold(a.Name, b.Name) && a.Typ == b.Typ && a.Ext == b.Ext && a.Vid == b.Vid && a.Vtyp32 == b.Vtyp32 &&
+		a.Dtyp == b.Dtyp && a.Tag == b.Tag && a.Enc == b.Enc && a.Concat == b.Concat
+}
+
+// check and add parsed attr
+func (l *loader) addAttr(ad *AttrData) error {
+	key := ad.key()
+	old, ok := l.aname[strings.ToLower(ad.Name)]
+	if !ok {
+		old = l.d.FindAttrName(ad.Name)
+	}
+	if old != nil {
+		if sameAttr(old, ad) {
+			return nil
+		}
+		return fmt.Errorf("Attribute %s already defined with different parameters", ad.Name)
+	}
+	if old, ok = l.abin[key]; !ok {
+		old = l.d.FindAllExtBin32(ad.Typ, ad.Ext, ad.Vid, ad.Vtyp32)
+	}
+	if old != nil {
+		return fmt.Errorf("Attribute %s conflicts with %s", ad.Name, old.Name)
+	}
+	l.attrs = append(l.attrs, ad)
+	l.aname[strings.ToLower(ad.Name)] = ad
+	l.abin[key] = ad
+	return nil
+}
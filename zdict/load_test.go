@@ -0,0 +1,248 @@
+package zdict
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeDict - write dictionary files to temp dir, returns dir
+func writeDict(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := writeDict(t, map[string]string{
+		"dictionary": `# main file
+$INCLUDE dictionary.acme
+$INCLUDE- dictionary.missing
+ATTRIBUTE	Test-Int	200	integer
+ATTRIBUTE	Test-Tagged	201	string	has_tag,encrypt=2
+VALUE	Test-Int	One	1
+VALUE	Test-Int	Two	0x2
+`,
+		"dictionary.acme": `VENDOR	Acme	999
+ATTRIBUTE	Acme-Old	1	string	Acme
+BEGIN-VENDOR	Acme
+ATTRIBUTE	Acme-Str	2	string
+ATTRIBUTE	Acme-Int	3	uint32
+END-VENDOR	Acme
+`,
+	})
+	d := New()
+	if err := d.LoadFile(filepath.Join(dir, "dictionary")); err != nil {
+		t.Fatal(err)
+	}
+	if v := d.FindVendorName("acme"); v == nil || v.ID != 999 || v.TypeLen != 1 || v.LenLen != 1 {
+		t.Fatalf("vendor %+v", v)
+	}
+	for name, want := range map[string]AttrData{
+		"Test-Int":    {Typ: 200, Dtyp: TypeInt},
+		"test-tagged": {Typ: 201, Dtyp: TypeString, Tag: true, Enc: EncTun},
+		"Acme-Old":    {Typ: AttrVSA, Vid: 999, Vtyp: 1, Vtyp32: 1, Dtyp: TypeString},
+		"Acme-Str":    {Typ: AttrVSA, Vid: 999, Vtyp: 2, Vtyp32: 2, Dtyp: TypeString},
+		"Acme-Int":    {Typ: AttrVSA, Vid: 999, Vtyp: 3, Vtyp32: 3, Dtyp: TypeInt},
+	} {
+		ad := d.FindAttrName(name)
+		if ad == nil {
+			t.Fatalf("%s not found", name)
+		}
+		got := *ad
+		got.Name = ""
+		if got != want {
+			t.Fatalf("%s: got %+v, want %+v", name, got, want)
+		}
+	}
+	if d.FindVSABin(999, 2) != d.FindAttrName("Acme-Str") || d.FindAttrBin(200) != d.FindAttrName("Test-Int") {
+		t.Fatal("binary lookup failed")
+	}
+	ad := d.FindAttrName("Test-Int")
+	if v := d.FindValueName(ad, "two"); v == nil || v.Val != 2 || d.FindValueNum(ad, 1).Name != "One" {
+		t.Fatal("value lookup failed")
+	}
+	// same definitions can be loaded again
+	if err := d.LoadFile(filepath.Join(dir, "dictionary")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for name, text := range map[string]string{
+		"keyword":   "FOO bar\n",
+		"type":      "ATTRIBUTE X 200 foo\n",
+		"number":    "ATTRIBUTE X 256 string\n",
+		"conflict":  "ATTRIBUTE User-Name 200 string\n",
+		"bin":       "ATTRIBUTE X 1 string\n",
+		"ext":       "ATTRIBUTE X 26.1 string\n",
+		"vendor":    "BEGIN-VENDOR Nobody\n",
+		"end":       "VENDOR A 1000\nBEGIN-VENDOR A\n",
+		"end2":      "END-VENDOR A\n",
+		"value":     "VALUE No-Attr X 1\n",
+		"valtype":   "VALUE User-Name X 1\n",
+		"valdup":    "VALUE Service-Type Login-User 2\n",
+		"vendordup": "VENDOR Other 14988\n",
+		"include":   "$INCLUDE missing\n",
+		"loop":      "$INCLUDE dictionary\n",
+	} {
+		dir := writeDict(t, map[string]string{"dictionary": "ATTRIBUTE Before-Error 250 string\n" + text})
+		d := Default.Clone()
+		err := d.LoadFile(filepath.Join(dir, "dictionary"))
+		if err == nil {
+			t.Fatalf("%s: no error", name)
+		}
+		if !strings.Contains(err.Error(), "dictionary") {
+			t.Fatalf("%s: no file name in error: %v", name, err)
+		}
+		if d.FindAttrName("Before-Error") != nil {
+			t.Fatalf("%s: dictionary is changed on error", name)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := writeDict(t, map[string]string{
+		"a":       "ATTRIBUTE A-Attr 200 string\n",
+		"b":       "ATTRIBUTE B-Attr 201 integer\nVALUE A-Attr X 1\n",
+		".hidden": "FOO\n",
+	})
+	d := New()
+	if err := d.LoadDir(dir); err == nil { // VALUE for string attr
+		t.Fatal("no error")
+	}
+	if d.FindAttrName("A-Attr") != nil {
+		t.Fatal("dictionary is changed on error")
+	}
+	os.WriteFile(filepath.Join(dir, "b"), []byte("ATTRIBUTE B-Attr 201 integer\nVALUE B-Attr X 1\n"), 0644)
+	if err := d.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if d.FindAttrName("A-Attr") == nil || d.FindAttrName("B-Attr") == nil {
+		t.Fatal("attrs are not loaded")
+	}
+}
+
+// part of FreeRADIUS dictionary.wimax with all its constructs
+const dictWiMAX = `# -*- text -*-
+VENDOR		WiMAX				24757	format=1,1,c
+
+BEGIN-VENDOR	WiMAX
+
+ATTRIBUTE	WiMAX-Capability			1	tlv
+ATTRIBUTE	WiMAX-Release				1.1	string
+ATTRIBUTE	WiMAX-Accounting-Capabilities		1.2	byte
+ATTRIBUTE	WiMAX-Hotlining-Capabilities		1.3	byte
+ATTRIBUTE	WiMAX-Idle-Mode-Notification-Cap	1.4	byte
+
+VALUE	WiMAX-Accounting-Capabilities	No-Accounting		0
+VALUE	WiMAX-Accounting-Capabilities	IP-Session-Based	1
+VALUE	WiMAX-Accounting-Capabilities	Flow-Based		2
+VALUE	WiMAX-Idle-Mode-Notification-Cap Not-Supported		0
+VALUE	WiMAX-Idle-Mode-Notification-Cap Supported		1
+
+ATTRIBUTE	WiMAX-Device-Authentication-Indicator	2	byte
+ATTRIBUTE	WiMAX-GMT-Timezone-offset		3	signed
+ATTRIBUTE	WiMAX-AAA-Session-Id			4	octets
+ATTRIBUTE	WiMAX-MSK				5	octets	encrypt=2
+ATTRIBUTE	WiMAX-hHA-IP-MIP4			6	ipaddr
+ATTRIBUTE	WiMAX-hHA-IP-MIP6			7	ipv6addr
+ATTRIBUTE	WiMAX-DHCPv4-Server			8	combo-ip
+ATTRIBUTE	WiMAX-MN-hHA-MIP4-SPI			11	integer
+
+ATTRIBUTE	WiMAX-Packet-Flow-Descriptor		28	tlv
+ATTRIBUTE	WiMAX-Packet-Data-Flow-Id		28.1	short
+ATTRIBUTE	WiMAX-Direction				28.4	byte
+VALUE	WiMAX-Direction			Bi-Directional		3
+ATTRIBUTE	WiMAX-Classifier			28.9	tlv
+ATTRIBUTE	WiMAX-Src-Spec				28.9.4	tlv
+ATTRIBUTE	WiMAX-Src-IP-Address			28.9.4.1	combo-ip
+
+ATTRIBUTE	WiMAX-Prepaid-Indicator			25	byte
+VALUE	WiMAX-Prepaid-Indicator		Prepaid			1
+
+END-VENDOR WiMAX
+`
+
+func TestLoadWiMAX(t *testing.T) {
+	var warns []string
+
+	dir := writeDict(t, map[string]string{"dictionary.wimax": dictWiMAX})
+	d := Default.Clone()
+	d.WarnLog = func(msg string) {
+		warns = append(warns, msg)
+	}
+	if err := d.LoadFile(filepath.Join(dir, "dictionary.wimax")); err != nil {
+		t.Fatal(err)
+	}
+	if v := d.FindVendorName("WiMAX"); v == nil || !v.Cont || v.TypeLen != 1 || v.LenLen != 1 {
+		t.Fatalf("vendor %+v", v)
+	}
+	for name, vtyp := range map[string]uint32{"WiMAX-Capability": 1, "WiMAX-MSK": 5, "WiMAX-DHCPv4-Server": 8, "WiMAX-Prepaid-Indicator": 25} {
+		if ad := d.FindAttrName(name); ad == nil || ad.Typ != AttrVSA || ad.Vid != 24757 || ad.Vtyp32 != vtyp {
+			t.Fatalf("%s: %+v", name, ad)
+		}
+	}
+	if ad := d.FindAttrName("WiMAX-MSK"); ad.Enc != EncTun {
+		t.Fatal("WiMAX-MSK is not encrypted")
+	}
+	if d.FindAttrName("WiMAX-Release") != nil || d.FindAttrName("WiMAX-Src-IP-Address") != nil {
+		t.Fatal("TLV attr is loaded")
+	}
+	if v := d.FindValueNum(d.FindAttrName("WiMAX-Prepaid-Indicator"), 1); v == nil || v.Name != "Prepaid" {
+		t.Fatal("value is not loaded")
+	}
+	if len(warns) != 9 || !strings.Contains(warns[0], "dictionary.wimax:7: ") || !strings.Contains(warns[0], "WiMAX-Release") {
+		t.Fatal(len(warns), warns)
+	}
+}
+
+func TestVendorFormat(t *testing.T) {
+	dir := writeDict(t, map[string]string{"dictionary": `VENDOR USR 429 format=4,0
+VENDOR Lucent 4846 format=2,1
+VENDOR Starent 8164 format=2,2
+BEGIN-VENDOR USR
+ATTRIBUTE USR-Big 0x9000 integer
+END-VENDOR USR
+BEGIN-VENDOR Lucent
+ATTRIBUTE Lucent-Max 65535 string
+END-VENDOR Lucent
+ATTRIBUTE Starent-Old 300 string Starent
+`})
+	d := New()
+	if err := d.LoadFile(filepath.Join(dir, "dictionary")); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string][2]int{"USR": {4, 0}, "Lucent": {2, 1}, "Starent": {2, 2}} {
+		if v := d.FindVendorName(name); v == nil || v.TypeLen != want[0] || v.LenLen != want[1] || v.Cont {
+			t.Fatalf("%s: %+v", name, v)
+		}
+	}
+	if d.FindVSABin32(429, 0x9000) == nil || d.FindVSABin32(4846, 65535) == nil || d.FindVSABin32(8164, 300) == nil {
+		t.Fatal("vendor attr is not found")
+	}
+	// byte VendorType API finds 1 byte types only
+	if ad := d.FindAttrName("Lucent-Max"); ad.Vtyp != 255 || ad.Vtyp32 != 65535 || d.FindVSABin(4846, 255) != nil {
+		t.Fatalf("got %+v", ad)
+	}
+	for name, text := range map[string]string{
+		"type len":  "VENDOR X 1000 format=3,1\n",
+		"len len":   "VENDOR X 1000 format=1,4\n",
+		"cont":      "VENDOR X 1000 format=2,1,c\n",
+		"flag":      "VENDOR X 1000 foo\n",
+		"attr num":  "VENDOR X 1000\nATTRIBUTE X-A 256 string X\n",
+		"attr num2": "VENDOR X 1000 format=2,1\nATTRIBUTE X-A 65536 string X\n",
+		"tlv old":   "VENDOR X 1000\nATTRIBUTE X-A 1.1 string X\n",
+	} {
+		dir = writeDict(t, map[string]string{"dictionary": text})
+		if err := New().LoadFile(filepath.Join(dir, "dictionary")); err == nil {
+			t.Fatalf("%s: no error", name)
+		}
+	}
+}
@@ -45,4 +45,82 @@ func init() {
 	addAttr(61, "NAS-Port-Type", TypeInt)
 	addAttr(62, "Port-Limit", TypeInt)
 	addAttr(63, "Login-LAT-Port", TypeString)
+
+	addValue(6, "Login-User", 1)
+	addValue(6, "Framed-User", 2)
+	addValue(6, "Callback-Login-User", 3)
+	addValue(6, "Callback-Framed-User", 4)
+	addValue(6, "Outbound-User", 5)
+	addValue(6, "Administrative-User", 6)
+	addValue(6, "NAS-Prompt-User", 7)
+	addValue(6, "Authenticate-Only", 8)
+	addValue(6, "Callback-NAS-Prompt", 9)
+	addValue(6, "Call-Check", 10)
+	addValue(6, "Callback-Administrative", 11)
+
+	addValue(7, "PPP", 1)
+	addValue(7, "SLIP", 2)
+	addValue(7, "ARAP", 3)
+	addValue(7, "Gandalf-SLML", 4)
+	addValue(7, "Xylogics-IPX-SLIP", 5)
+	addValue(7, "X.75-Synchronous", 6)
+
+	addValue(10, "None", 0)
+	addValue(10, "Broadcast", 1)
+	addValue(10, "Listen", 2)
+	addValue(10, "Broadcast-Listen", 3)
+
+	addValue(13, "None", 0)
+	addValue(13, "Van-Jacobson-TCP-IP", 1)
+	addValue(13, "IPX-Header-Compression", 2)
+	addValue(13, "Stac-LZS", 3)
+
+	addValue(15, "Telnet", 0)
+	addValue(15, "Rlogin", 1)
+	addValue(15, "TCP-Clear", 2)
+	addValue(15, "PortMaster", 3)
+	addValue(15, "LAT", 4)
+	addValue(15, "X25-PAD", 5)
+	addValue(15, "X25-T3POS", 6)
+	addValue(15, "TCP-Clear-Quiet", 8)
+
+	addValue(29, "Default", 0)
+	addValue(29, "RADIUS-Request", 1)
+
+	addValue(61, "Async", 0)
+	addValue(61, "Sync", 1)
+	addValue(61, "ISDN", 2)
+	addValue(61, "ISDN-V120", 3)
+	addValue(61, "ISDN-V110", 4)
+	addValue(61, "Virtual", 5)
+	addValue(61, "PIAFS", 6)
+	addValue(61, "HDLC-Clear-Channel", 7)
+	addValue(61, "X.25", 8)
+	addValue(61, "X.75", 9)
+	addValue(61, "G.3-Fax", 10)
+	addValue(61, "SDSL", 11)
+	addValue(61, "ADSL-CAP", 12)
+	addValue(61, "ADSL-DMT", 13)
+	addValue(61, "IDSL", 14)
+	addValue(61, "Ethernet", 15)
+	addValue(61, "xDSL", 16)
+	addValue(61, "Cable", 17)
+	addValue(61, "Wireless-Other", 18)
+	addValue(61, "Wireless-802.11", 19)
+	addValue(61, "Token-Ring", 20)
+	addValue(61, "FDDI", 21)
+	addValue(61, "Wireless-CDMA2000", 22)
+	addValue(61, "Wireless-UMTS", 23)
+	addValue(61, "Wireless-1X-EV", 24)
+	addValue(61, "IAPP", 25)
+	addValue(61, "FTTP", 26)
+	addValue(61, "Wireless-802.16", 27)
+	addValue(61, "Wireless-802.20", 28)
+	addValue(61, "Wireless-802.22", 29)
+	addValue(61, "PPPoA", 30)
+	addValue(61, "PPPoEoA", 31)
+	addValue(61, "PPPoEoE", 32)
+	addValue(61, "PPPoEoVLAN", 33)
+	addValue(61, "PPPoEoQinQ", 34)
+	addValue(61, "xPON", 35)
 }
@@ -13,4 +13,45 @@ func init() {
 	addAttr(49, "Acct-Terminate-Cause", TypeInt)
 	addAttr(50, "Acct-Multi-Session-Id", TypeString)
 	addAttr(51, "Acct-Link-Count", TypeInt)
+
+	addValue(40, "Start", 1)
+	addValue(40, "Stop", 2)
+	addValue(40, "Interim-Update", 3)
+	addValue(40, "Accounting-On", 7)
+	addValue(40, "Accounting-Off", 8)
+	addValue(40, "Tunnel-Start", 9)
+	addValue(40, "Tunnel-Stop", 10)
+	addValue(40, "Tunnel-Reject", 11)
+	addValue(40, "Tunnel-Link-Start", 12)
+	addValue(40, "Tunnel-Link-Stop", 13)
+	addValue(40, "Tunnel-Link-Reject", 14)
+	addValue(40, "Failed", 15)
+
+	addValue(45, "RADIUS", 1)
+	addValue(45, "Local", 2)
+	addValue(45, "Remote", 3)
+	addValue(45, "Diameter", 4)
+
+	addValue(49, "User-Request", 1)
+	addValue(49, "Lost-Carrier", 2)
+	addValue(49, "Lost-Service", 3)
+	addValue(49, "Idle-Timeout", 4)
+	addValue(49, "Session-Timeout", 5)
+	addValue(49, "Admin-Reset", 6)
+	addValue(49, "Admin-Reboot", 7)
+	addValue(49, "Port-Error", 8)
+	addValue(49, "NAS-Error", 9)
+	addValue(49, "NAS-Request", 10)
+	addValue(49, "NAS-Reboot", 11)
+	addValue(49, "Port-Unneeded", 12)
+	addValue(49, "Port-Preempted", 13)
+	addValue(49, "Port-Suspended", 14)
+	addValue(49, "Service-Unavailable", 15)
+	addValue(49, "Callback", 16)
+	addValue(49, "User-Error", 17)
+	addValue(49, "Host-Request", 18)
+	addValue(49, "Supplicant-Restart", 19)
+	addValue(49, "Reauthentication-Failure", 20)
+	addValue(49, "Port-Reinitialized", 21)
+	addValue(49, "Port-Administratively-Disabled", 22)
 }
@@ -14,4 +14,35 @@ func init() {
 
 	addAttr2(90, "Tunnel-Client-Auth-Id", TypeString, true, EncNone)
 	addAttr2(91, "Tunnel-Server-Auth-Id", TypeString, true, EncNone)
+
+	addValue(64, "PPTP", 1)
+	addValue(64, "L2F", 2)
+	addValue(64, "L2TP", 3)
+	addValue(64, "ATMP", 4)
+	addValue(64, "VTP", 5)
+	addValue(64, "AH", 6)
+	addValue(64, "IP", 7)
+	addValue(64, "MIN-IP", 8)
+	addValue(64, "ESP", 9)
+	addValue(64, "GRE", 10)
+	addValue(64, "DVS", 11)
+	addValue(64, "IP-in-IP", 12)
+	addValue(64, "VLAN", 13)
+
+	addValue(65, "IPv4", 1)
+	addValue(65, "IP", 1)
+	addValue(65, "IPv6", 2)
+	addValue(65, "NSAP", 3)
+	addValue(65, "HDLC", 4)
+	addValue(65, "BBN-1822", 5)
+	addValue(65, "IEEE-802", 6)
+	addValue(65, "E.163", 7)
+	addValue(65, "E.164", 8)
+	addValue(65, "F.69", 9)
+	addValue(65, "X.121", 10)
+	addValue(65, "IPX", 11)
+	addValue(65, "Appletalk", 12)
+	addValue(65, "DecNet-IV", 13)
+	addValue(65, "Banyan-Vines", 14)
+	addValue(65, "E.164-NSAP", 15)
 }
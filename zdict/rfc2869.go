@@ -15,7 +15,7 @@ func init() {
 	addAttr(76, "Prompt", TypeInt)
 	addAttr(77, "Connect-Info", TypeString)
 	addAttr(78, "Configuration-Token", TypeString)
-	addAttr(79, "EAP-Message", TypeRaw)
+	addAttrConcat(79, "EAP-Message", TypeRaw)
 	addAttr(80, "Message-Authenticator", TypeRaw)
 
 	addAttr(84, "ARAP-Challenge-Response", TypeRaw)
@@ -23,4 +23,7 @@ func init() {
 
 	addAttr(87, "NAS-Port-Id", TypeString)
 	addAttr(88, "Framed-Pool", TypeString)
+
+	addValue(76, "No-Echo", 0)
+	addValue(76, "Echo", 1)
 }
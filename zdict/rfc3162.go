@@ -0,0 +1,10 @@
+package zdict
+
+func init() {
+	addAttr(95, "NAS-IPv6-Address", TypeIP6)
+	addAttr(96, "Framed-Interface-Id", TypeIfID)
+	addAttr(97, "Framed-IPv6-Prefix", TypeIP6Pfx)
+	addAttr(98, "Login-IPv6-Host", TypeIP6)
+	addAttr(99, "Framed-IPv6-Route", TypeString)
+	addAttr(100, "Framed-IPv6-Pool", TypeString)
+}
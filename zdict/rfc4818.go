@@ -0,0 +1,5 @@
+package zdict
+
+func init() {
+	addAttr(123, "Delegated-IPv6-Prefix", TypeIP6Pfx)
+}
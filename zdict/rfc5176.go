@@ -0,0 +1,48 @@
+package zdict
+
+// Error-Cause attr type and values (RFC 5176)
+const (
+	AttrErrorCause = 101
+
+	CauseResidualRemoved      = 201 // Residual-Session-Context-Removed
+	CauseInvalidEAP           = 202 // Invalid-EAP-Packet
+	CauseUnsupportedAttr      = 401 // Unsupported-Attribute
+	CauseMissingAttr          = 402 // Missing-Attribute
+	CauseNASIDMismatch        = 403 // NAS-Identification-Mismatch
+	CauseInvalidRequest       = 404 // Invalid-Request
+	CauseUnsupportedService   = 405 // Unsupported-Service
+	CauseUnsupportedExtension = 406 // Unsupported-Extension
+	CauseInvalidAttrValue     = 407 // Invalid-Attribute-Value
+	CauseProhibited           = 501 // Administratively-Prohibited
+	CauseNotRoutable          = 502 // Request-Not-Routable
+	CauseSessionNotFound      = 503 // Session-Context-Not-Found
+	CauseSessionNotRemovable  = 504 // Session-Context-Not-Removable
+	CauseProxyError           = 505 // Other-Proxy-Processing-Error
+	CauseNoResources          = 506 // Resources-Unavailable
+	CauseRequestInitiated     = 507 // Request-Initiated
+	CauseMultipleSessions     = 508 // Multiple-Session-Selection-Unsupported
+)
+
+func init() {
+	addAttr(AttrErrorCause, "Error-Cause", TypeInt)
+
+	addValue(6, "Authorize-Only", 17) // Service-Type for CoA-Request (RFC 5176 §3.2)
+
+	addValue(AttrErrorCause, "Residual-Session-Context-Removed", CauseResidualRemoved)
+	addValue(AttrErrorCause, "Invalid-EAP-Packet", CauseInvalidEAP)
+	addValue(AttrErrorCause, "Unsupported-Attribute", CauseUnsupportedAttr)
+	addValue(AttrErrorCause, "Missing-Attribute", CauseMissingAttr)
+	addValue(AttrErrorCause, "NAS-Identification-Mismatch", CauseNASIDMismatch)
+	addValue(AttrErrorCause, "Invalid-Request", CauseInvalidRequest)
+	addValue(AttrErrorCause, "Unsupported-Service", CauseUnsupportedService)
+	addValue(AttrErrorCause, "Unsupported-Extension", CauseUnsupportedExtension)
+	addValue(AttrErrorCause, "Invalid-Attribute-Value", CauseInvalidAttrValue)
+	addValue(AttrErrorCause, "Administratively-Prohibited", CauseProhibited)
+	addValue(AttrErrorCause, "Request-Not-Routable", CauseNotRoutable)
+	addValue(AttrErrorCause, "Session-Context-Not-Found", CauseSessionNotFound)
+	addValue(AttrErrorCause, "Session-Context-Not-Removable", CauseSessionNotRemovable)
+	addValue(AttrErrorCause, "Other-Proxy-Processing-Error", CauseProxyError)
+	addValue(AttrErrorCause, "Resources-Unavailable", CauseNoResources)
+	addValue(AttrErrorCause, "Request-Initiated", CauseRequestInitiated)
+	addValue(AttrErrorCause, "Multiple-Session-Selection-Unsupported", CauseMultipleSessions)
+}
@@ -0,0 +1,11 @@
+package zdict
+
+func init() {
+	addAttrConcat(137, "PKM-SS-Cert", TypeRaw)
+	addAttrConcat(138, "PKM-CA-Cert", TypeRaw)
+	addAttr(139, "PKM-Config-Settings", TypeRaw)
+	addAttr(140, "PKM-Cryptosuite-List", TypeRaw)
+	addAttr(141, "PKM-SAID", TypeShort)
+	addAttr(142, "PKM-SA-Descriptor", TypeRaw)
+	addAttr(143, "PKM-Auth-Key", TypeRaw)
+}
@@ -0,0 +1,9 @@
+package zdict
+
+func init() {
+	addAttr(168, "Framed-IPv6-Address", TypeIP6)
+	addAttr(169, "DNS-Server-IPv6-Address", TypeIP6)
+	addAttr(170, "Route-IPv6-Information", TypeIP6Pfx)
+	addAttr(171, "Delegated-IPv6-Prefix-Pool", TypeString)
+	addAttr(172, "Stateful-IPv6-Address-Pool", TypeString)
+}
@@ -0,0 +1,6 @@
+package zdict
+
+func init() {
+	addExt(AttrExt1, 1, "Frag-Status", TypeInt)
+	addExt(AttrExt1, 2, "Proxy-State-Length", TypeInt)
+}
@@ -0,0 +1,6 @@
+package zdict
+
+func init() {
+	addExt(AttrExt1, 3, "Response-Length", TypeInt)
+	addExt(AttrExt1, 4, "Original-Packet-Code", TypeInt)
+}
@@ -4,6 +4,8 @@ package zdict
 const VendMikrotik uint32 = 14988
 
 func init() {
+	addVendor(VendMikrotik, "Mikrotik")
+
 	addVSA(VendMikrotik, 1, "Mikrotik-Recv-Limit", TypeInt)
 	addVSA(VendMikrotik, 2, "Mikrotik-Xmit-Limit", TypeInt)
 	addVSA(VendMikrotik, 3, "Mikrotik-Group", TypeString)
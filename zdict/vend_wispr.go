@@ -4,6 +4,8 @@ package zdict
 const VendWISPR uint32 = 14122
 
 func init() {
+	addVendor(VendWISPR, "WISPr")
+
 	addVSA(VendWISPR, 1, "WISPr-Location-ID", TypeString)
 	addVSA(VendWISPR, 2, "WISPr-Location-Name", TypeString)
 	addVSA(VendWISPR, 3, "WISPr-Logoff-URL", TypeString)
@@ -32,9 +32,46 @@ const (
 	TypeVSA               // VSA
 )
 
+// type names as in FreeRADIUS dictionary
+var typeNames = [...]string{
+	TypeRaw:    "octets",
+	TypeString: "string",
+	TypeIP4:    "ipaddr",
+	TypeIP4Pfx: "ipv4prefix",
+	TypeInt:    "integer",
+	TypeInt64:  "integer64",
+	TypeDate:   "date",
+	TypeIfID:   "ifid",
+	TypeIP6:    "ipv6addr",
+	TypeIP6Pfx: "ipv6prefix",
+	TypeByte:   "byte",
+	TypeEth:    "ether",
+	TypeShort:  "short",
+	TypeSInt:   "signed",
+	TypeVSA:    "vsa",
+}
+
+// TypeName - return name of data type
+func TypeName(dtyp int) string {
+	if dtyp < 0 || dtyp >= len(typeNames) {
+		return "unknown"
+	}
+	return typeNames[dtyp]
+}
+
 // RFC constants
 const (
-	AttrVSA = 26
+	AttrVSA     = 26
+	AttrMsgAuth = 80 // Message-Authenticator
+
+	// RFC6929
+	AttrExt1     = 241 // Extended-Type-1
+	AttrExt2     = 242 // Extended-Type-2
+	AttrExt3     = 243 // Extended-Type-3
+	AttrExt4     = 244 // Extended-Type-4
+	AttrLongExt1 = 245 // Long-Extended-Type-1
+	AttrLongExt2 = 246 // Long-Extended-Type-2
+	ExtEVS       = 26  // Extended-Type for Extended-Vendor-Specific
 
 	// RFC3575
 	AccessRequest      = 1
@@ -60,52 +97,210 @@ const (
 
 // AttrData - dictionary entry for Attr
 type AttrData struct {
-	Name string // Attr name
-	Typ  byte   // Attr type
-	Vid  uint32 // VendorID if Typ == AttrVSA
-	Vtyp byte   // VendorType if Typ == AttrVSA
-	Dtyp int    // Attr data type
-	Tag  bool   // Is Attr tagged
-	Enc  int    // Encription type
-}
-
-var (
-	strMap sync.Map // map by name
-	binMap sync.Map // map by attr data
+	Name   string // Attr name
+	Typ    byte   // Attr type
+	Ext    byte   // Extended-Type if Typ is extended (RFC 6929)
+	Vid    uint32 // VendorID if Typ == AttrVSA or Ext == ExtEVS
+	Vtyp   byte   // VendorType if Typ == AttrVSA or Ext == ExtEVS, low byte of Vtyp32
+	Vtyp32 uint32 // VendorType if Typ == AttrVSA or Ext == ExtEVS, full value for 2 and 4 byte VendorType
+	Dtyp   int    // Attr data type
+	Tag    bool   // Is Attr tagged
+	Enc    int    // Encription type
+	Concat bool   // Long data is split into consecutive Attrs
+}
+
+// VendorData - dictionary entry for Vendor
+type VendorData struct {
+	Name    string // Vendor name
+	ID      uint32 // VendorID
+	TypeLen int    // VendorType field len: 1, 2 or 4
+	LenLen  int    // VendorLen field len: 0, 1 or 2
+	Cont    bool   // WiMAX continuation byte after VendorLen
+}
+
+// ValueData - dictionary entry for named Attr value
+type ValueData struct {
+	Name string // Value name
+	Val  uint32 // Value
+}
+
+// keys for binary and value maps
+type (
+	binKey struct {
+		typ  byte   // attr type
+		ext  byte   // Extended-Type
+		vid  uint32 // VendorID
+		vtyp uint32 // VendorType
+	}
+	valNameKey struct {
+		akey binKey // attr binary key
+		name string // lower case value name
+	}
+	valNumKey struct {
+		akey binKey // attr binary key
+		val  uint32 // value
+	}
 )
 
+// Dictionary - attrs, vendors and named values storage
+type Dictionary struct {
+	strMap   sync.Map   // map by name
+	binMap   sync.Map   // map by attr data
+	vnameMap sync.Map   // vendors by name
+	vidMap   sync.Map   // vendors by VendorID
+	valName  sync.Map   // values by attr and name
+	valNum   sync.Map   // values by attr and number
+	loadMu   sync.Mutex // serialize dictionary loading
+
+	WarnLog func(msg string) // called for skipped entries on loading, may be nil
+}
+
+// Default - dictionary with built-in attrs, used by package level functions
+var Default = New()
+
+// New - create new empty dictionary
+func New() *Dictionary {
+	return &Dictionary{}
+}
+
+// Clone - create new dictionary with all entries and WarnLog of d
+func (d *Dictionary) Clone() *Dictionary {
+	nd := New()
+	nd.WarnLog = d.WarnLog
+	for _, m := range [...][2]*sync.Map{
+		{&d.strMap, &nd.strMap},
+		{&d.binMap, &nd.binMap},
+		{&d.vnameMap, &nd.vnameMap},
+		{&d.vidMap, &nd.vidMap},
+		{&d.valName, &nd.valName},
+		{&d.valNum, &nd.valNum},
+	} {
+		dst := m[1]
+		m[0].Range(func(k, v interface{}) bool {
+			dst.Store(k, v)
+			return true
+		})
+	}
+	return nd
+}
+
+// IsExt - attr type is Extended-Type or Long-Extended-Type (RFC 6929)
+func IsExt(typ byte) bool {
+	return typ >= AttrExt1 && typ <= AttrLongExt2
+}
+
+// IsLongExt - attr type is Long-Extended-Type (RFC 6929)
+func IsLongExt(typ byte) bool {
+	return typ == AttrLongExt1 || typ == AttrLongExt2
+}
+
 // makeKey - generate key for binary map
-func makeKey(typ byte, vid uint32, vtyp byte) uint64 {
-	if typ != AttrVSA {
-		return uint64(typ)
+func makeKey(typ, ext byte, vid uint32, vtyp uint32) binKey {
+	switch {
+	case typ == AttrVSA:
+		ext = 0
+	case IsExt(typ):
+		if ext != ExtEVS {
+			vid, vtyp = 0, 0
+		}
+	default:
+		return binKey{typ: typ}
+	}
+	return binKey{typ, ext, vid, vtyp}
+}
+
+// key - binary map key for Attr
+func (ad *AttrData) key() binKey {
+	vt := ad.Vtyp32
+	if vt == 0 { // entry is made without Vtyp32
+		vt = uint32(ad.Vtyp)
 	}
-	return (uint64(vid) << 16) | (uint64(vtyp) << 8) | uint64(typ)
+	return makeKey(ad.Typ, ad.Ext, ad.Vid, vt)
 }
 
-// add Attr to maps
-func addAttrGeneric(typ byte, vid uint32, vtyp byte, name string, dtyp int, tag bool, enc int) {
-	skey := strings.ToLower(name)
-	bkey := makeKey(typ, vid, vtyp)
-	adata := &AttrData{
+// setVtyp - set VendorType
+func (ad *AttrData) setVtyp(vtyp uint32) {
+	ad.Vtyp = byte(vtyp)
+	ad.Vtyp32 = vtyp
+}
+
+// add AttrData to maps
+func (d *Dictionary) addAttrData(adata *AttrData) {
+	d.binMap.Store(adata.key(), adata)
+	d.strMap.Store(strings.ToLower(adata.Name), adata)
+}
+
+// add named value to maps, first name is used for number if value has aliases
+func (d *Dictionary) addValueData(akey binKey, vdata *ValueData) {
+	d.valName.Store(valNameKey{akey, strings.ToLower(vdata.Name)}, vdata)
+	d.valNum.LoadOrStore(valNumKey{akey, vdata.Val}, vdata)
+}
+
+// add Vendor to maps
+func (d *Dictionary) addVendorData(vdata *VendorData) {
+	d.vnameMap.Store(strings.ToLower(vdata.Name), vdata)
+	d.vidMap.Store(vdata.ID, vdata)
+}
+
+// add Attr to default dictionary
+func addAttrGeneric(typ byte, vid uint32, vtyp uint32, name string, dtyp int, tag bool, enc int) {
+	Default.addAttrData(&AttrData{
+		Name:   name,
+		Typ:    typ,
+		Vid:    vid,
+		Vtyp:   byte(vtyp),
+		Vtyp32: vtyp,
+		Dtyp:   dtyp,
+		Tag:    tag,
+		Enc:    enc,
+	})
+}
+
+// add plain Attr which can be split into consecutive Attrs
+func addAttrConcat(typ byte, name string, dtyp int) {
+	Default.addAttrData(&AttrData{
+		Name:   name,
+		Typ:    typ,
+		Dtyp:   dtyp,
+		Concat: true,
+	})
+}
+
+// add extended Attr to dictionary
+func addExt(typ, ext byte, name string, dtyp int) {
+	Default.addAttrData(&AttrData{
 		Name: name,
 		Typ:  typ,
-		Vid:  vid,
-		Vtyp: vtyp,
+		Ext:  ext,
 		Dtyp: dtyp,
-		Tag:  tag,
-		Enc:  enc,
-	}
-	binMap.Store(bkey, adata)
-	strMap.Store(skey, adata)
+	})
+}
+
+// add named value for plain Attr to default dictionary
+func addValue(typ byte, name string, val uint32) {
+	Default.addValueData(makeKey(typ, 0, 0, 0), &ValueData{
+		Name: name,
+		Val:  val,
+	})
+}
+
+// add Vendor to default dictionary
+func addVendor(vid uint32, name string) {
+	Default.addVendorData(&VendorData{
+		Name:    name,
+		ID:      vid,
+		TypeLen: 1,
+		LenLen:  1,
+	})
 }
 
 // add VSA to dictionary
-func addVSA(vid uint32, vtyp byte, name string, dtyp int) {
+func addVSA(vid uint32, vtyp uint32, name string, dtyp int) {
 	addAttrGeneric(AttrVSA, vid, vtyp, name, dtyp, false, EncNone)
 }
 
 // add VSA with tag and enc to dictionary
-func addVSA2(vid uint32, vtyp byte, name string, dtyp int, tag bool, enc int) {
+func addVSA2(vid uint32, vtyp uint32, name string, dtyp int, tag bool, enc int) {
 	addAttrGeneric(AttrVSA, vid, vtyp, name, dtyp, tag, enc)
 }
 
@@ -120,19 +315,49 @@ func addAttr2(typ byte, name string, dtyp int, tag bool, enc int) {
 }
 
 // FindAttrBin - find plain Attr by type
-func FindAttrBin(typ byte) *AttrData {
-	return FindAllAttrBin(typ, 0, 0)
+func (d *Dictionary) FindAttrBin(typ byte) *AttrData {
+	return d.FindAllAttrBin(typ, 0, 0)
 }
 
 // FindVSABin - find VSA by VendorID and VendorType
-func FindVSABin(vid uint32, vtyp byte) *AttrData {
-	return FindAllAttrBin(AttrVSA, vid, vtyp)
+func (d *Dictionary) FindVSABin(vid uint32, vtyp byte) *AttrData {
+	return d.FindVSABin32(vid, uint32(vtyp))
 }
 
-// FindAllAttrBin - find any Attr by binary params
-func FindAllAttrBin(typ byte, vid uint32, vtyp byte) *AttrData {
-	k := makeKey(typ, vid, vtyp)
-	v, ok := binMap.Load(k)
+// FindVSABin32 - find VSA by VendorID and 1, 2 or 4 byte VendorType
+func (d *Dictionary) FindVSABin32(vid, vtyp uint32) *AttrData {
+	return d.FindAllAttrBin32(AttrVSA, vid, vtyp)
+}
+
+// FindExtBin - find extended Attr by type and Extended-Type
+func (d *Dictionary) FindExtBin(typ, ext byte) *AttrData {
+	return d.FindAllExtBin(typ, ext, 0, 0)
+}
+
+// FindEVSBin - find Extended-Vendor-Specific Attr by type, VendorID and VendorType
+func (d *Dictionary) FindEVSBin(typ byte, vid uint32, vtyp byte) *AttrData {
+	return d.FindAllExtBin(typ, ExtEVS, vid, vtyp)
+}
+
+// FindAllAttrBin - find any not extended Attr by binary params
+func (d *Dictionary) FindAllAttrBin(typ byte, vid uint32, vtyp byte) *AttrData {
+	return d.FindAllAttrBin32(typ, vid, uint32(vtyp))
+}
+
+// FindAllAttrBin32 - find any not extended Attr by binary params with 1, 2 or 4 byte VendorType
+func (d *Dictionary) FindAllAttrBin32(typ byte, vid, vtyp uint32) *AttrData {
+	return d.FindAllExtBin32(typ, 0, vid, vtyp)
+}
+
+// FindAllExtBin - find any Attr by binary params
+func (d *Dictionary) FindAllExtBin(typ, ext byte, vid uint32, vtyp byte) *AttrData {
+	return d.FindAllExtBin32(typ, ext, vid, uint32(vtyp))
+}
+
+// FindAllExtBin32 - find any Attr by binary params with 1, 2 or 4 byte VendorType
+func (d *Dictionary) FindAllExtBin32(typ, ext byte, vid, vtyp uint32) *AttrData {
+	k := makeKey(typ, ext, vid, vtyp)
+	v, ok := d.binMap.Load(k)
 	if !ok {
 		return nil
 	}
@@ -140,11 +365,124 @@ func FindAllAttrBin(typ byte, vid uint32, vtyp byte) *AttrData {
 }
 
 // FindAttrName - find any attr by name
-func FindAttrName(name string) *AttrData {
+func (d *Dictionary) FindAttrName(name string) *AttrData {
 	k := strings.ToLower(name)
-	v, ok := strMap.Load(k)
+	v, ok := d.strMap.Load(k)
 	if !ok {
 		return nil
 	}
 	return v.(*AttrData)
 }
+
+// FindVendorName - find Vendor by name
+func (d *Dictionary) FindVendorName(name string) *VendorData {
+	v, ok := d.vnameMap.Load(strings.ToLower(name))
+	if !ok {
+		return nil
+	}
+	return v.(*VendorData)
+}
+
+// FindVendorID - find Vendor by VendorID
+func (d *Dictionary) FindVendorID(vid uint32) *VendorData {
+	v, ok := d.vidMap.Load(vid)
+	if !ok {
+		return nil
+	}
+	return v.(*VendorData)
+}
+
+// FindValueName - find named value for Attr by name
+func (d *Dictionary) FindValueName(ad *AttrData, name string) *ValueData {
+	if ad == nil {
+		return nil
+	}
+	v, ok := d.valName.Load(valNameKey{ad.key(), strings.ToLower(name)})
+	if !ok {
+		return nil
+	}
+	return v.(*ValueData)
+}
+
+// FindValueNum - find named value for Attr by number
+func (d *Dictionary) FindValueNum(ad *AttrData, val uint32) *ValueData {
+	if ad == nil {
+		return nil
+	}
+	v, ok := d.valNum.Load(valNumKey{ad.key(), val})
+	if !ok {
+		return nil
+	}
+	return v.(*ValueData)
+}
+
+// FindAttrBin - find plain Attr by type in default dictionary
+func FindAttrBin(typ byte) *AttrData {
+	return Default.FindAttrBin(typ)
+}
+
+// FindVSABin - find VSA by VendorID and VendorType in default dictionary
+func FindVSABin(vid uint32, vtyp byte) *AttrData {
+	return Default.FindVSABin(vid, vtyp)
+}
+
+// FindVSABin32 - find VSA by VendorID and 1, 2 or 4 byte VendorType in default dictionary
+func FindVSABin32(vid, vtyp uint32) *AttrData {
+	return Default.FindVSABin32(vid, vtyp)
+}
+
+// FindAllAttrBin - find any not extended Attr by binary params in default dictionary
+func FindAllAttrBin(typ byte, vid uint32, vtyp byte) *AttrData {
+	return Default.FindAllAttrBin(typ, vid, vtyp)
+}
+
+// FindAllAttrBin32 - find any not extended Attr by binary params with 1, 2 or 4 byte VendorType
+// in default dictionary
+func FindAllAttrBin32(typ byte, vid, vtyp uint32) *AttrData {
+	return Default.FindAllAttrBin32(typ, vid, vtyp)
+}
+
+// FindExtBin - find extended Attr by type and Extended-Type in default dictionary
+func FindExtBin(typ, ext byte) *AttrData {
+	return Default.FindExtBin(typ, ext)
+}
+
+// FindEVSBin - find Extended-Vendor-Specific Attr in default dictionary
+func FindEVSBin(typ byte, vid uint32, vtyp byte) *AttrData {
+	return Default.FindEVSBin(typ, vid, vtyp)
+}
+
+// FindAllExtBin - find any Attr by binary params in default dictionary
+func FindAllExtBin(typ, ext byte, vid uint32, vtyp byte) *AttrData {
+	return Default.FindAllExtBin(typ, ext, vid, vtyp)
+}
+
+// FindAllExtBin32 - find any Attr by binary params with 1, 2 or 4 byte VendorType in default dictionary
+func FindAllExtBin32(typ, ext byte, vid, vtyp uint32) *AttrData {
+	return Default.FindAllExtBin32(typ, ext, vid, vtyp)
+}
+
+// FindAttrName - find any attr by name in default dictionary
+func FindAttrName(name string) *AttrData {
+	return Default.FindAttrName(name)
+}
+
+// FindVendorName - find Vendor by name in default dictionary
+func FindVendorName(name string) *VendorData {
+	return Default.FindVendorName(name)
+}
+
+// FindVendorID - find Vendor by VendorID in default dictionary
+func FindVendorID(vid uint32) *VendorData {
+	return Default.FindVendorID(vid)
+}
+
+// FindValueName - find named value for Attr by name in default dictionary
+func FindValueName(ad *AttrData, name string) *ValueData {
+	return Default.FindValueName(ad, name)
+}
+
+// FindValueNum - find named value for Attr by number in default dictionary
+func FindValueNum(ad *AttrData, val uint32) *ValueData {
+	return Default.FindValueNum(ad, val)
+}
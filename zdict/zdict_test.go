@@ -0,0 +1,53 @@
+package zdict
+
+import "testing"
+
+func TestBuiltinValues(t *testing.T) {
+	for _, c := range []struct {
+		attr, name string
+		val        uint32
+	}{
+		{"Service-Type", "Framed-User", 2},
+		{"Service-Type", "Authorize-Only", 17},
+		{"Acct-Status-Type", "Interim-Update", 3},
+		{"NAS-Port-Type", "Virtual", 5},
+		{"Acct-Terminate-Cause", "Session-Timeout", 5},
+		{"Framed-Protocol", "PPP", 1},
+		{"Tunnel-Type", "VLAN", 13},
+		{"Error-Cause", "Session-Context-Not-Found", CauseSessionNotFound},
+	} {
+		ad := FindAttrName(c.attr)
+		if ad == nil {
+			t.Fatalf("%s not found", c.attr)
+		}
+		if v := FindValueName(ad, c.name); v == nil || v.Val != c.val {
+			t.Fatalf("%s %s: got %+v", c.attr, c.name, v)
+		}
+		if v := FindValueNum(ad, c.val); v == nil || v.Name != c.name {
+			t.Fatalf("%s %d: got %+v", c.attr, c.val, v)
+		}
+	}
+	if FindValueName(FindAttrName("Service-Type"), "No-Such-Value") != nil || FindValueNum(FindAttrName("User-Name"), 1) != nil {
+		t.Fatal("unknown value is found")
+	}
+}
+
+func TestIsolated(t *testing.T) {
+	if d := New(); d.FindAttrName("User-Name") != nil || d.FindAttrBin(1) != nil {
+		t.Fatal("new dictionary is not empty")
+	}
+	c := Default.Clone()
+	if c.FindAttrName("User-Name") != Default.FindAttrName("User-Name") {
+		t.Fatal("clone has no built-in attrs")
+	}
+	c.addAttrData(&AttrData{Name: "Clone-Only", Typ: 250, Dtyp: TypeString})
+	c.addVendorData(&VendorData{Name: "Clone-Vendor", ID: 65000, TypeLen: 1, LenLen: 1})
+	c.addValueData(c.FindAttrName("Service-Type").key(), &ValueData{Name: "Clone-Value", Val: 100})
+	if Default.FindAttrName("Clone-Only") != nil || Default.FindAttrBin(250) != nil || Default.FindVendorID(65000) != nil ||
+		Default.FindValueNum(FindAttrName("Service-Type"), 100) != nil {
+		t.Fatal("Default is changed by clone")
+	}
+	if c.FindAttrBin(250) == nil || c.FindVendorName("clone-vendor") == nil || c.FindValueName(c.FindAttrName("Service-Type"), "Clone-Value") == nil {
+		t.Fatal("clone is not changed")
+	}
+}
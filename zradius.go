@@ -14,6 +14,18 @@ const (
 	MaxPLen = 4096 // Max packet len
 )
 
+// printData - attr data for printing
+func (attr *Attr) printData(pkt *Packet) string {
+	v, err := attr.GetValue(pkt)
+	if err != nil {
+		return fmt.Sprintf("%x (%v)", attr.data, err)
+	}
+	if n := attr.GetEnum(pkt); n != "" {
+		return fmt.Sprintf("%s (%+v)", n, v)
+	}
+	return fmt.Sprintf("%+v", v)
+}
+
 // String - печать пакета
 func (pkt *Packet) String() string {
 	var (
@@ -24,23 +36,32 @@ func (pkt *Packet) String() string {
 	r += fmt.Sprintf("Addr: %s\n", pkt.addr)
 	r += fmt.Sprintf("Code: %d, ID: %d, Len: %d, Auth: %x\n", pkt.code, pkt.id, pkt.len, pkt.auth)
 	for _, a := range pkt.attr {
-		at = zdict.FindAllAttrBin(a.typ, a.vid, a.vtyp)
+		at = a.atyp
 		if at != nil {
 			r += fmt.Sprintf("%s", at.Name)
 		} else {
 			r += fmt.Sprintf("T: %d", a.typ)
 		}
 		r += fmt.Sprintf(", L: %d", a.len)
+		if a.ext != 0 {
+			r += fmt.Sprintf(", Ext: %d", a.ext)
+			if a.ext == zdict.ExtEVS {
+				r += fmt.Sprintf(", VID: %d, VT: %d", a.vid, a.vtyp)
+			}
+		}
+		if a.tagd {
+			r += fmt.Sprintf(", Tag: %d", a.tag)
+		}
 		if a.typ == zdict.AttrVSA {
 			r += fmt.Sprintf(", VID: %d, VT: %d, VL: %d", a.vid, a.vtyp, a.vlen)
-			if a.vlen > 0 {
-				r += fmt.Sprintf(", DATA: %+v\n", a.GetEData(pkt))
+			if a.vlen > 0 || len(a.data) > 0 {
+				r += fmt.Sprintf(", DATA: %s\n", a.printData(pkt))
 			} else {
 				r += fmt.Sprint("\n")
 			}
 		} else {
 			if a.len > 0 {
-				r += fmt.Sprintf(", DATA: %+v\n", a.GetEData(pkt))
+				r += fmt.Sprintf(", DATA: %s\n", a.printData(pkt))
 			} else {
 				r += fmt.Sprint("\n")
 			}